@@ -9,6 +9,9 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Children returns the direct child nodes in source order, leaving out
+	// missing optional ones.
+	Children() []Node
 }
 
 type Statement interface {
@@ -21,6 +24,27 @@ type Expression interface {
 	expressionNode()
 }
 
+// Comments holds the comments attached to a statement. They are only
+// collected when the lexer emits comments, see lexer.WithComments.
+type Comments struct {
+	LeadingComment  string // the comment lines directly before the statement
+	TrailingComment string // a comment on the same line after the statement
+}
+
+func (c *Comments) comments() *Comments { return c }
+
+// CommentsOf returns the comments of node, or nil if node can't carry any.
+func CommentsOf(node Node) *Comments {
+	if isNilNode(node) {
+		return nil
+	}
+
+	if c, ok := node.(interface{ comments() *Comments }); ok {
+		return c.comments()
+	}
+	return nil
+}
+
 type Program struct {
 	Statements []Statement
 }
@@ -33,6 +57,14 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Children() []Node {
+	var children []Node
+	for _, statement := range p.Statements {
+		children = appendNodes(children, statement)
+	}
+	return children
+}
+
 func (program *Program) String() string {
 	var out bytes.Buffer
 
@@ -44,26 +76,60 @@ func (program *Program) String() string {
 }
 
 type LetStatement struct {
-	Token token.Token // the token.Let token
+	Comments
+
+	Token   token.Token // the token.Let or token.CONST token
+	Name    *Identifier
+	Pattern Expression // *ArrayPattern or *HashPattern, set instead of Name when destructuring
+	Value   Expression // nil for a declaration without initializer
+	IsConst bool       // declared with const, the binding must not be reassigned
+
+	// Additional holds the bindings following the first one in
+	// `let a = 1, b = 2;`.
+	Additional []LetBinding
+}
+
+type LetBinding struct {
 	Name  *Identifier
-	Value Expression
+	Value Expression // nil for a binding without initializer
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 
+func (ls *LetStatement) Children() []Node {
+	children := appendNodes(nil, ls.Name, ls.Pattern, ls.Value)
+	for _, binding := range ls.Additional {
+		children = appendNodes(children, binding.Name, binding.Value)
+	}
+	return children
+}
+
 func (letStatement *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(letStatement.TokenLiteral())
 	out.WriteString(" ")
-	out.WriteString(letStatement.Name.String())
-	out.WriteString(" = ")
+	if letStatement.Pattern != nil {
+		out.WriteString(letStatement.Pattern.String())
+	} else {
+		out.WriteString(letStatement.Name.String())
+	}
 
 	if letStatement.Value != nil {
+		out.WriteString(" = ")
 		out.WriteString(letStatement.Value.String())
 	}
 
+	for _, binding := range letStatement.Additional {
+		out.WriteString(", ")
+		out.WriteString(binding.Name.String())
+		if binding.Value != nil {
+			out.WriteString(" = ")
+			out.WriteString(binding.Value.String())
+		}
+	}
+
 	out.WriteString(";")
 
 	return out.String()
@@ -76,10 +142,13 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Children() []Node     { return nil }
 
 func (identifier *Identifier) String() string { return identifier.Value }
 
 type ReturnStatement struct {
+	Comments
+
 	Token       token.Token // the token.RETURN token
 	ReturnValue Expression
 }
@@ -87,6 +156,10 @@ type ReturnStatement struct {
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
 
+func (rs *ReturnStatement) Children() []Node {
+	return appendNodes(nil, rs.ReturnValue)
+}
+
 func (returnStatement *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -102,7 +175,469 @@ func (returnStatement *ReturnStatement) String() string {
 	return out.String()
 }
 
+// YieldStatement hands a value from a generator to its caller.
+type YieldStatement struct {
+	Comments
+
+	Token token.Token // the 'yield' token
+	Value Expression  // nil for a bare yield
+}
+
+func (ys *YieldStatement) statementNode()       {}
+func (ys *YieldStatement) TokenLiteral() string { return ys.Token.Literal }
+
+func (ys *YieldStatement) Children() []Node {
+	return appendNodes(nil, ys.Value)
+}
+
+func (ys *YieldStatement) String() string {
+	if ys.Value != nil {
+		return ys.Token.Literal + " " + ys.Value.String() + ";"
+	}
+	return ys.Token.Literal + ";"
+}
+
+type BreakStatement struct {
+	Comments
+
+	Token token.Token // the 'break' token
+	Label *Identifier // nil for an unlabeled break
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+
+func (bs *BreakStatement) Children() []Node {
+	return appendNodes(nil, bs.Label)
+}
+
+func (bs *BreakStatement) String() string {
+	if bs.Label != nil {
+		return bs.Token.Literal + " " + bs.Label.String() + ";"
+	}
+	return bs.Token.Literal + ";"
+}
+
+// PrintStatement is the parenless form `print a, b;`.
+type PrintStatement struct {
+	Comments
+
+	Token token.Token // the 'print' token
+	Args  []Expression
+}
+
+func (ps *PrintStatement) statementNode()       {}
+func (ps *PrintStatement) TokenLiteral() string { return ps.Token.Literal }
+
+func (ps *PrintStatement) Children() []Node {
+	var children []Node
+	for _, arg := range ps.Args {
+		children = appendNodes(children, arg)
+	}
+	return children
+}
+
+func (ps *PrintStatement) String() string {
+	args := []string{}
+	for _, arg := range ps.Args {
+		args = append(args, arg.String())
+	}
+
+	if len(args) == 0 {
+		return ps.TokenLiteral() + ";"
+	}
+	return ps.TokenLiteral() + " " + strings.Join(args, ", ") + ";"
+}
+
+type ImportStatement struct {
+	Comments
+
+	Token token.Token // the 'import' token
+	Path  string
+	Names []*Identifier // nil when the whole module is imported
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+
+func (is *ImportStatement) Children() []Node {
+	var children []Node
+	for _, name := range is.Names {
+		children = appendNodes(children, name)
+	}
+	return children
+}
+
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("import ")
+	if is.Names != nil {
+		names := []string{}
+		for _, name := range is.Names {
+			names = append(names, name.String())
+		}
+
+		out.WriteString("{ ")
+		out.WriteString(strings.Join(names, ", "))
+		out.WriteString(" } from ")
+	}
+	out.WriteString(`"` + is.Path + `";`)
+
+	return out.String()
+}
+
+type ExportStatement struct {
+	Comments
+
+	Token       token.Token // the 'export' token
+	Declaration Statement   // the exported let or const, nil when Names is set
+	Names       []*Identifier
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+
+func (es *ExportStatement) Children() []Node {
+	children := appendNodes(nil, es.Declaration)
+	for _, name := range es.Names {
+		children = appendNodes(children, name)
+	}
+	return children
+}
+
+func (es *ExportStatement) String() string {
+	if es.Declaration != nil {
+		return "export " + es.Declaration.String()
+	}
+
+	names := []string{}
+	for _, name := range es.Names {
+		names = append(names, name.String())
+	}
+
+	return "export { " + strings.Join(names, ", ") + " };"
+}
+
+// InterfaceDeclaration declares the methods a value has to provide, like
+// `interface Shape { fn area(): Float; }`.
+type InterfaceDeclaration struct {
+	Comments
+
+	Token   token.Token // the 'interface' token
+	Name    *Identifier
+	Methods []MethodSignature
+}
+
+func (id *InterfaceDeclaration) statementNode()       {}
+func (id *InterfaceDeclaration) TokenLiteral() string { return id.Token.Literal }
+
+func (id *InterfaceDeclaration) Children() []Node {
+	children := appendNodes(nil, id.Name)
+	for _, method := range id.Methods {
+		children = appendNodes(children, method.Name)
+		for _, parameter := range method.Parameters {
+			children = appendNodes(children, parameter.Name, parameter.Type)
+		}
+		children = appendNodes(children, method.ReturnType)
+	}
+	return children
+}
+
+func (id *InterfaceDeclaration) String() string {
+	if len(id.Methods) == 0 {
+		return id.TokenLiteral() + " " + id.Name.String() + " {}"
+	}
+
+	var out bytes.Buffer
+
+	out.WriteString(id.TokenLiteral() + " " + id.Name.String() + " { ")
+	for _, method := range id.Methods {
+		out.WriteString(method.String() + "; ")
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// MethodSignature is a method of an interface without a body.
+type MethodSignature struct {
+	Token      token.Token // the 'fn' token
+	Name       *Identifier
+	Parameters []TypedParameter
+	ReturnType *Identifier // nil if the method returns nothing
+}
+
+func (ms MethodSignature) String() string {
+	parameters := []string{}
+	for _, parameter := range ms.Parameters {
+		parameters = append(parameters, parameter.String())
+	}
+
+	out := ms.Token.Literal + " " + ms.Name.String() + "(" + strings.Join(parameters, ", ") + ")"
+	if ms.ReturnType != nil {
+		out += ": " + ms.ReturnType.String()
+	}
+
+	return out
+}
+
+// TypedParameter is a parameter with an optional type, like `by: Float`.
+type TypedParameter struct {
+	Name *Identifier
+	Type *Identifier // nil if the type is left out
+}
+
+func (tp TypedParameter) String() string {
+	if tp.Type == nil {
+		return tp.Name.String()
+	}
+	return tp.Name.String() + ": " + tp.Type.String()
+}
+
+type ContinueStatement struct {
+	Comments
+
+	Token token.Token // the 'continue' token
+	Label *Identifier // nil for an unlabeled continue
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+
+func (cs *ContinueStatement) Children() []Node {
+	return appendNodes(nil, cs.Label)
+}
+
+func (cs *ContinueStatement) String() string {
+	if cs.Label != nil {
+		return cs.Token.Literal + " " + cs.Label.String() + ";"
+	}
+	return cs.Token.Literal + ";"
+}
+
+type LabeledStatement struct {
+	Comments
+
+	Token     token.Token // the label's token.IDENT token
+	Label     *Identifier
+	Statement Statement
+}
+
+func (ls *LabeledStatement) statementNode()       {}
+func (ls *LabeledStatement) TokenLiteral() string { return ls.Token.Literal }
+
+func (ls *LabeledStatement) Children() []Node {
+	return appendNodes(nil, ls.Label, ls.Statement)
+}
+
+func (ls *LabeledStatement) String() string {
+	return ls.Label.String() + ": " + ls.Statement.String()
+}
+
+// DecoratedStatement is a function declaration preceded by decorators, like
+// `@memoize fn fib(n) { ... }`.
+type DecoratedStatement struct {
+	Comments
+
+	Token       token.Token // the first '@' token
+	Decorators  []Decorator
+	Declaration Statement // a named fn or a let binding a function
+}
+
+// Decorator is a single `@name` or `@name(args)`.
+type Decorator struct {
+	Token      token.Token // the '@' token
+	Expression Expression  // an *Identifier or a *CallExpression
+}
+
+func (d Decorator) String() string { return "@" + d.Expression.String() }
+
+func (ds *DecoratedStatement) statementNode()       {}
+func (ds *DecoratedStatement) TokenLiteral() string { return ds.Token.Literal }
+
+func (ds *DecoratedStatement) Children() []Node {
+	var children []Node
+	for _, decorator := range ds.Decorators {
+		children = appendNodes(children, decorator.Expression)
+	}
+	return appendNodes(children, ds.Declaration)
+}
+
+func (ds *DecoratedStatement) String() string {
+	var out bytes.Buffer
+
+	for _, decorator := range ds.Decorators {
+		out.WriteString(decorator.String())
+		out.WriteString("\n")
+	}
+	out.WriteString(ds.Declaration.String())
+
+	return out.String()
+}
+
+type AssignStatement struct {
+	Comments
+
+	Token  token.Token // the '=' token
+	Target Expression  // *Identifier, *IndexExpression or *DotExpression
+	Value  Expression
+}
+
+func (as *AssignStatement) statementNode()       {}
+func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
+
+func (as *AssignStatement) Children() []Node {
+	return appendNodes(nil, as.Target, as.Value)
+}
+
+func (as *AssignStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(as.Target.String())
+	out.WriteString(" = ")
+	if as.Value != nil {
+		out.WriteString(as.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// Scope is the scope a ScopedAssignStatement assigns in.
+type Scope int
+
+const (
+	Outer  Scope = iota // the nearest enclosing scope that defines the name
+	Global              // the top-level scope
+)
+
+// ScopedAssignStatement is an assignment to a name in an enclosing scope,
+// like `outer x = 5;` or `global x = 5;`.
+type ScopedAssignStatement struct {
+	Comments
+
+	Token  token.Token // the token.OUTER or token.GLOBAL token
+	Scope  Scope
+	Target *Identifier
+	Value  Expression
+}
+
+func (ss *ScopedAssignStatement) statementNode()       {}
+func (ss *ScopedAssignStatement) TokenLiteral() string { return ss.Token.Literal }
+
+func (ss *ScopedAssignStatement) Children() []Node {
+	return appendNodes(nil, ss.Target, ss.Value)
+}
+
+func (ss *ScopedAssignStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ss.TokenLiteral() + " ")
+	out.WriteString(ss.Target.String())
+	out.WriteString(" = ")
+	if ss.Value != nil {
+		out.WriteString(ss.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// AssignExpression is an assignment used as an expression, evaluating to the
+// assigned value.
+type AssignExpression struct {
+	Token  token.Token // the '=' token
+	Target Expression  // *Identifier, *IndexExpression or *DotExpression
+	Value  Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+
+func (ae *AssignExpression) Children() []Node {
+	return appendNodes(nil, ae.Target, ae.Value)
+}
+
+func (ae *AssignExpression) String() string {
+	return "(" + ae.Target.String() + " = " + ae.Value.String() + ")"
+}
+
+type MultiAssignStatement struct {
+	Comments
+
+	Token   token.Token // the '=' token
+	Targets []Expression
+	Values  []Expression
+}
+
+func (ms *MultiAssignStatement) statementNode()       {}
+func (ms *MultiAssignStatement) TokenLiteral() string { return ms.Token.Literal }
+
+func (ms *MultiAssignStatement) Children() []Node {
+	var children []Node
+	for _, target := range ms.Targets {
+		children = appendNodes(children, target)
+	}
+	for _, value := range ms.Values {
+		children = appendNodes(children, value)
+	}
+	return children
+}
+
+func (ms *MultiAssignStatement) String() string {
+	var out bytes.Buffer
+
+	targets := []string{}
+	for _, target := range ms.Targets {
+		targets = append(targets, target.String())
+	}
+
+	values := []string{}
+	for _, value := range ms.Values {
+		values = append(values, value.String())
+	}
+
+	out.WriteString(strings.Join(targets, ", "))
+	out.WriteString(" = ")
+	out.WriteString(strings.Join(values, ", "))
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// GuardedStatement is a statement followed by an if or unless modifier, like
+// `return x if done;`.
+type GuardedStatement struct {
+	Comments
+
+	Token     token.Token // the 'if' or 'unless' token
+	Statement Statement
+	Condition Expression
+	Negate    bool // true for unless
+}
+
+func (gs *GuardedStatement) statementNode()       {}
+func (gs *GuardedStatement) TokenLiteral() string { return gs.Token.Literal }
+
+func (gs *GuardedStatement) Children() []Node {
+	return appendNodes(nil, gs.Statement, gs.Condition)
+}
+
+func (gs *GuardedStatement) String() string {
+	statement := gs.Statement.String()
+	terminator := ""
+	if strings.HasSuffix(statement, ";") {
+		statement = strings.TrimSuffix(statement, ";")
+		terminator = ";"
+	}
+
+	return statement + " " + gs.TokenLiteral() + " " + gs.Condition.String() + terminator
+}
+
 type ExpressionStatement struct {
+	Comments
+
 	Token      token.Token // the first token of the expression
 	Expression Expression
 }
@@ -110,6 +645,10 @@ type ExpressionStatement struct {
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
 
+func (es *ExpressionStatement) Children() []Node {
+	return appendNodes(nil, es.Expression)
+}
+
 func (expressionStatement *ExpressionStatement) String() string {
 	if expressionStatement.Expression != nil {
 		return expressionStatement.Expression.String()
@@ -119,14 +658,27 @@ func (expressionStatement *ExpressionStatement) String() string {
 }
 
 type IntegerLiteral struct {
-	Token token.Token
-	Value int64
+	Token  token.Token
+	Value  int64
+	Suffix string // "i", "u", "l" or ""
 }
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Children() []Node     { return nil }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Token  token.Token
+	Value  float64
+	Suffix string // "f" or ""
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Children() []Node     { return nil }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 type PrefixExpression struct {
 	Token    token.Token // the prefix token e.g. !
 	Operator string
@@ -135,6 +687,11 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+
+func (pe *PrefixExpression) Children() []Node {
+	return appendNodes(nil, pe.Right)
+}
+
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -155,6 +712,11 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+
+func (ie *InfixExpression) Children() []Node {
+	return appendNodes(nil, ie.Left, ie.Right)
+}
+
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -174,6 +736,7 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Children() []Node     { return nil }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 type IfExpression struct {
@@ -185,6 +748,11 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+
+func (ie *IfExpression) Children() []Node {
+	return appendNodes(nil, ie.Condition, ie.Consequence, ie.Alternative)
+}
+
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -201,31 +769,273 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
-type BlockStatement struct {
-	Token      token.Token // the { token
-	Statements []Statement
+type UnlessExpression struct {
+	Token       token.Token // the 'unless' token
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
 }
 
-func (bs *BlockStatement) statementNode()       {}
-func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
-func (bs *BlockStatement) String() string {
+func (ue *UnlessExpression) expressionNode()      {}
+func (ue *UnlessExpression) TokenLiteral() string { return ue.Token.Literal }
+
+func (ue *UnlessExpression) Children() []Node {
+	return appendNodes(nil, ue.Condition, ue.Consequence, ue.Alternative)
+}
+
+func (ue *UnlessExpression) String() string {
 	var out bytes.Buffer
 
-	for _, statement := range bs.Statements {
-		out.WriteString(statement.String())
+	out.WriteString("unless")
+	out.WriteString(ue.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ue.Consequence.String())
+
+	if ue.Alternative != nil {
+		out.WriteString("else ")
+		out.WriteString(ue.Alternative.String())
 	}
 
 	return out.String()
 }
 
-type FunctionLiteral struct {
-	Token      token.Token // The 'fn' token
-	Parameters []*Identifier
-	Body       *BlockStatement
+type WhileExpression struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (we *WhileExpression) expressionNode()      {}
+func (we *WhileExpression) TokenLiteral() string { return we.Token.Literal }
+
+func (we *WhileExpression) Children() []Node {
+	return appendNodes(nil, we.Condition, we.Body)
+}
+
+func (we *WhileExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(we.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(we.Body.String())
+
+	return out.String()
+}
+
+// ForInExpression is `for (x in iterable) { ... }` or, with an index
+// variable, `for (i, x in iterable) { ... }`.
+type ForInExpression struct {
+	Token    token.Token // the 'for' token
+	Index    *Identifier // nil without an index variable
+	Var      *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fie *ForInExpression) expressionNode()      {}
+func (fie *ForInExpression) TokenLiteral() string { return fie.Token.Literal }
+
+func (fie *ForInExpression) Children() []Node {
+	return appendNodes(nil, fie.Index, fie.Var, fie.Iterable, fie.Body)
+}
+
+func (fie *ForInExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	if fie.Index != nil {
+		out.WriteString(fie.Index.String())
+		out.WriteString(", ")
+	}
+	out.WriteString(fie.Var.String())
+	out.WriteString(" in ")
+	out.WriteString(fie.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fie.Body.String())
+
+	return out.String()
+}
+
+type DoWhileExpression struct {
+	Token     token.Token // the 'do' token
+	Body      *BlockStatement
+	Condition Expression
+}
+
+func (dwe *DoWhileExpression) expressionNode()      {}
+func (dwe *DoWhileExpression) TokenLiteral() string { return dwe.Token.Literal }
+
+func (dwe *DoWhileExpression) Children() []Node {
+	return appendNodes(nil, dwe.Body, dwe.Condition)
+}
+
+func (dwe *DoWhileExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("do ")
+	out.WriteString(dwe.Body.String())
+	out.WriteString(" while")
+	out.WriteString(dwe.Condition.String())
+
+	return out.String()
+}
+
+type TryExpression struct {
+	Token   token.Token // the 'try' token
+	Body    *BlockStatement
+	Binding *Identifier // nil when the catch clause binds no error
+	Handler *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+
+func (te *TryExpression) Children() []Node {
+	return appendNodes(nil, te.Body, te.Binding, te.Handler)
+}
+
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try {")
+	out.WriteString(te.Body.String())
+	out.WriteString("} catch ")
+	if te.Binding != nil {
+		out.WriteString("(" + te.Binding.String() + ") ")
+	}
+	out.WriteString("{")
+	out.WriteString(te.Handler.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// MatchExpression is `match subject { pattern => result, ... }`. A `_`
+// pattern matches any value, an *ArrayPattern or *HashPattern destructures
+// the subject and binds its names for the arm's result.
+type MatchExpression struct {
+	Token   token.Token // the 'match' token
+	Subject Expression
+	Arms    []MatchArm
+}
+
+type MatchArm struct {
+	Patterns []Expression // alternatives separated by '|'
+	Result   Expression
+}
+
+// Bindings returns the names the arm's destructuring patterns bring into
+// scope for its result.
+func (ma MatchArm) Bindings() []*Identifier {
+	bindings := []*Identifier{}
+	for _, pattern := range ma.Patterns {
+		switch pattern := pattern.(type) {
+		case *ArrayPattern:
+			bindings = append(bindings, pattern.Elements...)
+			if pattern.Rest != nil {
+				bindings = append(bindings, pattern.Rest)
+			}
+		case *HashPattern:
+			bindings = append(bindings, pattern.Keys...)
+		}
+	}
+	return bindings
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+
+func (me *MatchExpression) Children() []Node {
+	children := appendNodes(nil, me.Subject)
+	for _, arm := range me.Arms {
+		for _, pattern := range arm.Patterns {
+			children = appendNodes(children, pattern)
+		}
+		children = appendNodes(children, arm.Result)
+	}
+	return children
+}
+
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range me.Arms {
+		patterns := []string{}
+		for _, pattern := range arm.Patterns {
+			patterns = append(patterns, pattern.String())
+		}
+		arms = append(arms, strings.Join(patterns, " | ")+" => "+arm.Result.String())
+	}
+
+	out.WriteString("match ")
+	out.WriteString(me.Subject.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+type BlockStatement struct {
+	Token      token.Token // the { token
+	Statements []Statement
+}
+
+func (bs *BlockStatement) statementNode()       {}
+func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+
+func (bs *BlockStatement) Children() []Node {
+	var children []Node
+	for _, statement := range bs.Statements {
+		children = appendNodes(children, statement)
+	}
+	return children
+}
+
+func (bs *BlockStatement) String() string {
+	var out bytes.Buffer
+
+	for _, statement := range bs.Statements {
+		out.WriteString(statement.String())
+	}
+
+	return out.String()
+}
+
+type BlockExpression struct {
+	Token token.Token // the '{' token
+	Block *BlockStatement
+}
+
+func (be *BlockExpression) expressionNode()      {}
+func (be *BlockExpression) TokenLiteral() string { return be.Token.Literal }
+
+func (be *BlockExpression) Children() []Node {
+	return appendNodes(nil, be.Block)
+}
+
+func (be *BlockExpression) String() string { return "{" + be.Block.String() + "}" }
+
+type FunctionLiteral struct {
+	Token      token.Token // The 'fn' token
+	Name       *Identifier // bound within the body, nil for anonymous functions
+	Parameters []*Identifier
+	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+
+func (fl *FunctionLiteral) Children() []Node {
+	children := appendNodes(nil, fl.Name)
+	for _, parameter := range fl.Parameters {
+		children = appendNodes(children, parameter)
+	}
+	return appendNodes(children, fl.Body)
+}
+
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
@@ -234,6 +1044,9 @@ func (fl *FunctionLiteral) String() string {
 	}
 
 	out.WriteString(fl.TokenLiteral())
+	if fl.Name != nil {
+		out.WriteString(" " + fl.Name.String())
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(")")
@@ -242,14 +1055,60 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+type MacroLiteral struct {
+	Token      token.Token // The 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+
+func (ml *MacroLiteral) Children() []Node {
+	var children []Node
+	for _, parameter := range ml.Parameters {
+		children = appendNodes(children, parameter)
+	}
+	return appendNodes(children, ml.Body)
+}
+
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
+
 type CallExpression struct {
-	Token     token.Token // The '(' token
-	Function  Expression  // Identifier or FunctionLiteral
-	Arguments []Expression
+	Token          token.Token // The '(' token
+	Function       Expression  // Identifier or FunctionLiteral
+	Arguments      []Expression
+	NamedArguments []*NamedArgument // always after Arguments
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+
+func (ce *CallExpression) Children() []Node {
+	children := appendNodes(nil, ce.Function)
+	for _, argument := range ce.Arguments {
+		children = appendNodes(children, argument)
+	}
+	for _, argument := range ce.NamedArguments {
+		children = appendNodes(children, argument)
+	}
+	return children
+}
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -257,6 +1116,9 @@ func (ce *CallExpression) String() string {
 	for _, a := range ce.Arguments {
 		args = append(args, a.String())
 	}
+	for _, a := range ce.NamedArguments {
+		args = append(args, a.String())
+	}
 
 	out.WriteString(ce.Function.String())
 	out.WriteString("(")
@@ -266,6 +1128,21 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+type NamedArgument struct {
+	Token token.Token // the name token
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+
+func (na *NamedArgument) Children() []Node {
+	return appendNodes(nil, na.Name, na.Value)
+}
+
+func (na *NamedArgument) String() string { return na.Name.String() + ": " + na.Value.String() }
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -273,8 +1150,31 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Children() []Node     { return nil }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// BytesLiteral is a byte string like `b"\x00\x01"`.
+type BytesLiteral struct {
+	Token token.Token // the token.BYTES token
+	Value []byte
+}
+
+func (bl *BytesLiteral) expressionNode()      {}
+func (bl *BytesLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BytesLiteral) Children() []Node     { return nil }
+func (bl *BytesLiteral) String() string       { return quoteBytes(bl.Value) }
+
+type RegexLiteral struct {
+	Token   token.Token // the token.REGEX token
+	Pattern string
+	Flags   string
+}
+
+func (rl *RegexLiteral) expressionNode()      {}
+func (rl *RegexLiteral) TokenLiteral() string { return rl.Token.Literal }
+func (rl *RegexLiteral) Children() []Node     { return nil }
+func (rl *RegexLiteral) String() string       { return "/" + rl.Pattern + "/" + rl.Flags }
+
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
@@ -282,6 +1182,15 @@ type ArrayLiteral struct {
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+
+func (al *ArrayLiteral) Children() []Node {
+	var children []Node
+	for _, element := range al.Elements {
+		children = appendNodes(children, element)
+	}
+	return children
+}
+
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -297,14 +1206,98 @@ func (al *ArrayLiteral) String() string {
 	return out.String()
 }
 
+type ListComprehension struct {
+	Token    token.Token // the '[' token
+	Element  Expression
+	Var      *Identifier
+	Iterable Expression
+	Filter   Expression // nil without an if clause
+}
+
+func (lc *ListComprehension) expressionNode()      {}
+func (lc *ListComprehension) TokenLiteral() string { return lc.Token.Literal }
+
+func (lc *ListComprehension) Children() []Node {
+	return appendNodes(nil, lc.Element, lc.Var, lc.Iterable, lc.Filter)
+}
+
+func (lc *ListComprehension) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("[")
+	out.WriteString(lc.Element.String())
+	out.WriteString(" for ")
+	out.WriteString(lc.Var.String())
+	out.WriteString(" in ")
+	out.WriteString(lc.Iterable.String())
+	if lc.Filter != nil {
+		out.WriteString(" if ")
+		out.WriteString(lc.Filter.String())
+	}
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type TupleLiteral struct {
+	Token    token.Token // the '(' token
+	Elements []Expression
+}
+
+func (tl *TupleLiteral) expressionNode()      {}
+func (tl *TupleLiteral) TokenLiteral() string { return tl.Token.Literal }
+
+func (tl *TupleLiteral) Children() []Node {
+	var children []Node
+	for _, element := range tl.Elements {
+		children = appendNodes(children, element)
+	}
+	return children
+}
+
+func (tl *TupleLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range tl.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("(")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type SpreadElement struct {
+	Token token.Token // the '...' token
+	Value Expression
+}
+
+func (se *SpreadElement) expressionNode()      {}
+func (se *SpreadElement) TokenLiteral() string { return se.Token.Literal }
+
+func (se *SpreadElement) Children() []Node {
+	return appendNodes(nil, se.Value)
+}
+
+func (se *SpreadElement) String() string { return "..." + se.Value.String() }
+
 type IndexExpression struct {
-	Token token.Token // the '[' token
-	Left  Expression
-	Index Expression
+	Token   token.Token // the '[' token
+	Left    Expression
+	Index   Expression
+	FromEnd bool // the index is a negative integer literal like -1
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+
+func (ie *IndexExpression) Children() []Node {
+	return appendNodes(nil, ie.Left, ie.Index)
+}
+
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -317,19 +1310,160 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression is `left[start:stop:step]`, where every component may be
+// left out, e.g. `arr[1:]` or `arr[::2]`.
+type SliceExpression struct {
+	Token token.Token // the '[' token
+	Left  Expression
+	Start Expression // nil if left out
+	Stop  Expression // nil if left out
+	Step  Expression // nil if left out
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+
+func (se *SliceExpression) Children() []Node {
+	return appendNodes(nil, se.Left, se.Start, se.Stop, se.Step)
+}
+
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.Stop != nil {
+		out.WriteString(se.Stop.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+type DotExpression struct {
+	Token    token.Token // the '.' token
+	Left     Expression
+	Property *Identifier
+}
+
+func (de *DotExpression) expressionNode()      {}
+func (de *DotExpression) TokenLiteral() string { return de.Token.Literal }
+
+func (de *DotExpression) Children() []Node {
+	return appendNodes(nil, de.Left, de.Property)
+}
+
+func (de *DotExpression) String() string {
+	return "(" + de.Left.String() + "." + de.Property.String() + ")"
+}
+
+// OptionalIndexExpression is `a?.b` or, if Computed, `a?.[i]`. It evaluates
+// to null instead of failing when Left is null.
+type OptionalIndexExpression struct {
+	Token    token.Token // the '?.' token
+	Left     Expression
+	Index    Expression // an *Identifier unless Computed
+	Computed bool
+}
+
+func (oie *OptionalIndexExpression) expressionNode()      {}
+func (oie *OptionalIndexExpression) TokenLiteral() string { return oie.Token.Literal }
+
+func (oie *OptionalIndexExpression) Children() []Node {
+	return appendNodes(nil, oie.Left, oie.Index)
+}
+
+func (oie *OptionalIndexExpression) String() string {
+	if oie.Computed {
+		return "(" + oie.Left.String() + "?.[" + oie.Index.String() + "])"
+	}
+	return "(" + oie.Left.String() + "?." + oie.Index.String() + ")"
+}
+
+// NonNullAssertion is a postfix `x!` asserting that x is not null.
+type NonNullAssertion struct {
+	Token token.Token // the '!' token
+	Left  Expression
+}
+
+func (nna *NonNullAssertion) expressionNode()      {}
+func (nna *NonNullAssertion) TokenLiteral() string { return nna.Token.Literal }
+
+func (nna *NonNullAssertion) Children() []Node {
+	return appendNodes(nil, nna.Left)
+}
+
+func (nna *NonNullAssertion) String() string { return "(" + nna.Left.String() + "!)" }
+
+// TypeofExpression is `typeof x`, evaluating to the name of x's type.
+type TypeofExpression struct {
+	Token token.Token // the 'typeof' token
+	Value Expression
+}
+
+func (te *TypeofExpression) expressionNode()      {}
+func (te *TypeofExpression) TokenLiteral() string { return te.Token.Literal }
+
+func (te *TypeofExpression) Children() []Node {
+	return appendNodes(nil, te.Value)
+}
+
+func (te *TypeofExpression) String() string { return "(typeof " + te.Value.String() + ")" }
+
+// TypeCheckExpression is `x is Int`, checking the type of x.
+type TypeCheckExpression struct {
+	Token    token.Token // the 'is' token
+	Value    Expression
+	TypeName *Identifier
+}
+
+func (tce *TypeCheckExpression) expressionNode()      {}
+func (tce *TypeCheckExpression) TokenLiteral() string { return tce.Token.Literal }
+
+func (tce *TypeCheckExpression) Children() []Node {
+	return appendNodes(nil, tce.Value, tce.TypeName)
+}
+
+func (tce *TypeCheckExpression) String() string {
+	return "(" + tce.Value.String() + " is " + tce.TypeName.String() + ")"
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
 type HashLiteral struct {
 	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Pairs []HashPair  // in source order
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+
+func (hl *HashLiteral) Children() []Node {
+	var children []Node
+	for _, pair := range hl.Pairs {
+		children = appendNodes(children, pair.Key, pair.Value)
+	}
+	return children
+}
+
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 
 	out.WriteString("{")
@@ -338,3 +1472,136 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+type StructField struct {
+	Name  *Identifier
+	Value Expression
+}
+
+type StructLiteral struct {
+	Token  token.Token // the 'struct' token
+	Fields []StructField
+}
+
+func (sl *StructLiteral) expressionNode()      {}
+func (sl *StructLiteral) TokenLiteral() string { return sl.Token.Literal }
+
+func (sl *StructLiteral) Children() []Node {
+	var children []Node
+	for _, field := range sl.Fields {
+		children = appendNodes(children, field.Name, field.Value)
+	}
+	return children
+}
+
+func (sl *StructLiteral) String() string {
+	if len(sl.Fields) == 0 {
+		return "struct {}"
+	}
+
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, field := range sl.Fields {
+		fields = append(fields, field.Name.String()+": "+field.Value.String())
+	}
+
+	out.WriteString("struct { ")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+type ArrayPattern struct {
+	Token    token.Token // the '[' token
+	Elements []*Identifier
+	Rest     *Identifier // the identifier after '...', if any
+}
+
+func (ap *ArrayPattern) expressionNode()      {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+
+func (ap *ArrayPattern) Children() []Node {
+	var children []Node
+	for _, element := range ap.Elements {
+		children = appendNodes(children, element)
+	}
+	return appendNodes(children, ap.Rest)
+}
+
+func (ap *ArrayPattern) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type HashPattern struct {
+	Token token.Token // the '{' token
+	Keys  []*Identifier
+}
+
+func (hp *HashPattern) expressionNode()      {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+
+func (hp *HashPattern) Children() []Node {
+	var children []Node
+	for _, key := range hp.Keys {
+		children = appendNodes(children, key)
+	}
+	return children
+}
+
+func (hp *HashPattern) String() string {
+	var out bytes.Buffer
+
+	keys := []string{}
+	for _, key := range hp.Keys {
+		keys = append(keys, key.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(keys, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type RangeExpression struct {
+	Token     token.Token // the '..' or '..<' token
+	Start     Expression
+	End       Expression
+	Exclusive bool // true for '..<'
+}
+
+func (re *RangeExpression) expressionNode()      {}
+func (re *RangeExpression) TokenLiteral() string { return re.Token.Literal }
+
+func (re *RangeExpression) Children() []Node {
+	return appendNodes(nil, re.Start, re.End)
+}
+
+func (re *RangeExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(re.Start.String())
+	out.WriteString(re.Token.Literal)
+	out.WriteString(re.End.String())
+	out.WriteString(")")
+
+	return out.String()
+}
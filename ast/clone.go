@@ -0,0 +1,388 @@
+package ast
+
+// Clone returns a deep copy of node. The copy shares no nodes, slices or
+// maps with the original.
+func Clone(node Node) Node {
+	clone := cloneNode(node)
+
+	if comments := CommentsOf(node); comments != nil {
+		*CommentsOf(clone) = *comments
+	}
+
+	return clone
+}
+
+func cloneNode(node Node) Node {
+	if isNilNode(node) {
+		return nil
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		return &Program{Statements: cloneStatements(n.Statements)}
+
+	case *LetStatement:
+		clone := &LetStatement{
+			Token:   n.Token,
+			Name:    cloneIdentifier(n.Name),
+			Pattern: cloneExpression(n.Pattern),
+			Value:   cloneExpression(n.Value),
+			IsConst: n.IsConst,
+		}
+		if n.Additional != nil {
+			clone.Additional = make([]LetBinding, len(n.Additional))
+			for i, binding := range n.Additional {
+				clone.Additional[i] = LetBinding{Name: cloneIdentifier(binding.Name), Value: cloneExpression(binding.Value)}
+			}
+		}
+		return clone
+
+	case *AssignStatement:
+		return &AssignStatement{Token: n.Token, Target: cloneExpression(n.Target), Value: cloneExpression(n.Value)}
+
+	case *ScopedAssignStatement:
+		return &ScopedAssignStatement{Token: n.Token, Scope: n.Scope, Target: cloneIdentifier(n.Target), Value: cloneExpression(n.Value)}
+
+	case *AssignExpression:
+		return &AssignExpression{Token: n.Token, Target: cloneExpression(n.Target), Value: cloneExpression(n.Value)}
+
+	case *MultiAssignStatement:
+		return &MultiAssignStatement{Token: n.Token, Targets: cloneExpressions(n.Targets), Values: cloneExpressions(n.Values)}
+
+	case *ReturnStatement:
+		return &ReturnStatement{Token: n.Token, ReturnValue: cloneExpression(n.ReturnValue)}
+
+	case *PrintStatement:
+		return &PrintStatement{Token: n.Token, Args: cloneExpressions(n.Args)}
+
+	case *GuardedStatement:
+		clone := &GuardedStatement{Token: n.Token, Condition: cloneExpression(n.Condition), Negate: n.Negate}
+		if !isNilNode(n.Statement) {
+			clone.Statement = Clone(n.Statement).(Statement)
+		}
+		return clone
+
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: n.Token, Expression: cloneExpression(n.Expression)}
+
+	case *ImportStatement:
+		return &ImportStatement{Token: n.Token, Path: n.Path, Names: cloneIdentifiers(n.Names)}
+
+	case *ExportStatement:
+		clone := &ExportStatement{Token: n.Token, Names: cloneIdentifiers(n.Names)}
+		if !isNilNode(n.Declaration) {
+			clone.Declaration = Clone(n.Declaration).(Statement)
+		}
+		return clone
+
+	case *InterfaceDeclaration:
+		clone := &InterfaceDeclaration{Token: n.Token, Name: cloneIdentifier(n.Name)}
+		if n.Methods != nil {
+			clone.Methods = make([]MethodSignature, len(n.Methods))
+			for i, method := range n.Methods {
+				clone.Methods[i] = MethodSignature{Token: method.Token, Name: cloneIdentifier(method.Name), ReturnType: cloneIdentifier(method.ReturnType)}
+				if method.Parameters != nil {
+					clone.Methods[i].Parameters = make([]TypedParameter, len(method.Parameters))
+					for j, parameter := range method.Parameters {
+						clone.Methods[i].Parameters[j] = TypedParameter{Name: cloneIdentifier(parameter.Name), Type: cloneIdentifier(parameter.Type)}
+					}
+				}
+			}
+		}
+		return clone
+
+	case *YieldStatement:
+		return &YieldStatement{Token: n.Token, Value: cloneExpression(n.Value)}
+
+	case *BreakStatement:
+		return &BreakStatement{Token: n.Token, Label: cloneIdentifier(n.Label)}
+
+	case *ContinueStatement:
+		return &ContinueStatement{Token: n.Token, Label: cloneIdentifier(n.Label)}
+
+	case *DecoratedStatement:
+		clone := &DecoratedStatement{Token: n.Token}
+		for _, decorator := range n.Decorators {
+			clone.Decorators = append(clone.Decorators, Decorator{Token: decorator.Token, Expression: cloneExpression(decorator.Expression)})
+		}
+		if !isNilNode(n.Declaration) {
+			clone.Declaration = Clone(n.Declaration).(Statement)
+		}
+		return clone
+
+	case *LabeledStatement:
+		clone := &LabeledStatement{Token: n.Token, Label: cloneIdentifier(n.Label)}
+		if !isNilNode(n.Statement) {
+			clone.Statement = Clone(n.Statement).(Statement)
+		}
+		return clone
+
+	case *BlockStatement:
+		return cloneBlock(n)
+
+	case *Identifier:
+		return cloneIdentifier(n)
+
+	case *IntegerLiteral:
+		clone := *n
+		return &clone
+
+	case *FloatLiteral:
+		clone := *n
+		return &clone
+
+	case *Boolean:
+		clone := *n
+		return &clone
+
+	case *StringLiteral:
+		clone := *n
+		return &clone
+
+	case *BytesLiteral:
+		return &BytesLiteral{Token: n.Token, Value: append([]byte{}, n.Value...)}
+
+	case *RegexLiteral:
+		clone := *n
+		return &clone
+
+	case *PrefixExpression:
+		return &PrefixExpression{Token: n.Token, Operator: n.Operator, Right: cloneExpression(n.Right)}
+
+	case *InfixExpression:
+		return &InfixExpression{
+			Token:    n.Token,
+			Left:     cloneExpression(n.Left),
+			Operator: n.Operator,
+			Right:    cloneExpression(n.Right),
+		}
+
+	case *IfExpression:
+		return &IfExpression{
+			Token:       n.Token,
+			Condition:   cloneExpression(n.Condition),
+			Consequence: cloneBlock(n.Consequence),
+			Alternative: cloneBlock(n.Alternative),
+		}
+
+	case *UnlessExpression:
+		return &UnlessExpression{
+			Token:       n.Token,
+			Condition:   cloneExpression(n.Condition),
+			Consequence: cloneBlock(n.Consequence),
+			Alternative: cloneBlock(n.Alternative),
+		}
+
+	case *WhileExpression:
+		return &WhileExpression{Token: n.Token, Condition: cloneExpression(n.Condition), Body: cloneBlock(n.Body)}
+
+	case *ForInExpression:
+		return &ForInExpression{
+			Token:    n.Token,
+			Index:    cloneIdentifier(n.Index),
+			Var:      cloneIdentifier(n.Var),
+			Iterable: cloneExpression(n.Iterable),
+			Body:     cloneBlock(n.Body),
+		}
+
+	case *DoWhileExpression:
+		return &DoWhileExpression{Token: n.Token, Body: cloneBlock(n.Body), Condition: cloneExpression(n.Condition)}
+
+	case *TryExpression:
+		return &TryExpression{
+			Token:   n.Token,
+			Body:    cloneBlock(n.Body),
+			Binding: cloneIdentifier(n.Binding),
+			Handler: cloneBlock(n.Handler),
+		}
+
+	case *BlockExpression:
+		return &BlockExpression{Token: n.Token, Block: cloneBlock(n.Block)}
+
+	case *MatchExpression:
+		clone := &MatchExpression{Token: n.Token, Subject: cloneExpression(n.Subject)}
+		if n.Arms != nil {
+			clone.Arms = make([]MatchArm, len(n.Arms))
+			for i, arm := range n.Arms {
+				clone.Arms[i] = MatchArm{Patterns: cloneExpressions(arm.Patterns), Result: cloneExpression(arm.Result)}
+			}
+		}
+		return clone
+
+	case *FunctionLiteral:
+		return &FunctionLiteral{
+			Token:      n.Token,
+			Name:       cloneIdentifier(n.Name),
+			Parameters: cloneIdentifiers(n.Parameters),
+			Body:       cloneBlock(n.Body),
+		}
+
+	case *MacroLiteral:
+		return &MacroLiteral{Token: n.Token, Parameters: cloneIdentifiers(n.Parameters), Body: cloneBlock(n.Body)}
+
+	case *CallExpression:
+		clone := &CallExpression{
+			Token:     n.Token,
+			Function:  cloneExpression(n.Function),
+			Arguments: cloneExpressions(n.Arguments),
+		}
+		for _, argument := range n.NamedArguments {
+			clone.NamedArguments = append(clone.NamedArguments, Clone(argument).(*NamedArgument))
+		}
+		return clone
+
+	case *NamedArgument:
+		return &NamedArgument{Token: n.Token, Name: cloneIdentifier(n.Name), Value: cloneExpression(n.Value)}
+
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: n.Token, Elements: cloneExpressions(n.Elements)}
+
+	case *ListComprehension:
+		return &ListComprehension{
+			Token:    n.Token,
+			Element:  cloneExpression(n.Element),
+			Var:      cloneIdentifier(n.Var),
+			Iterable: cloneExpression(n.Iterable),
+			Filter:   cloneExpression(n.Filter),
+		}
+
+	case *TupleLiteral:
+		return &TupleLiteral{Token: n.Token, Elements: cloneExpressions(n.Elements)}
+
+	case *DotExpression:
+		return &DotExpression{Token: n.Token, Left: cloneExpression(n.Left), Property: cloneIdentifier(n.Property)}
+
+	case *OptionalIndexExpression:
+		return &OptionalIndexExpression{
+			Token:    n.Token,
+			Left:     cloneExpression(n.Left),
+			Index:    cloneExpression(n.Index),
+			Computed: n.Computed,
+		}
+
+	case *TypeofExpression:
+		return &TypeofExpression{Token: n.Token, Value: cloneExpression(n.Value)}
+
+	case *TypeCheckExpression:
+		return &TypeCheckExpression{Token: n.Token, Value: cloneExpression(n.Value), TypeName: cloneIdentifier(n.TypeName)}
+
+	case *NonNullAssertion:
+		return &NonNullAssertion{Token: n.Token, Left: cloneExpression(n.Left)}
+
+	case *SpreadElement:
+		return &SpreadElement{Token: n.Token, Value: cloneExpression(n.Value)}
+
+	case *IndexExpression:
+		return &IndexExpression{
+			Token:   n.Token,
+			Left:    cloneExpression(n.Left),
+			Index:   cloneExpression(n.Index),
+			FromEnd: n.FromEnd,
+		}
+
+	case *SliceExpression:
+		return &SliceExpression{
+			Token: n.Token,
+			Left:  cloneExpression(n.Left),
+			Start: cloneExpression(n.Start),
+			Stop:  cloneExpression(n.Stop),
+			Step:  cloneExpression(n.Step),
+		}
+
+	case *HashLiteral:
+		clone := &HashLiteral{Token: n.Token}
+		if n.Pairs != nil {
+			clone.Pairs = make([]HashPair, len(n.Pairs))
+			for i, pair := range n.Pairs {
+				clone.Pairs[i] = HashPair{Key: cloneExpression(pair.Key), Value: cloneExpression(pair.Value)}
+			}
+		}
+		return clone
+
+	case *StructLiteral:
+		clone := &StructLiteral{Token: n.Token}
+		if n.Fields != nil {
+			clone.Fields = make([]StructField, len(n.Fields))
+			for i, field := range n.Fields {
+				clone.Fields[i] = StructField{Name: cloneIdentifier(field.Name), Value: cloneExpression(field.Value)}
+			}
+		}
+		return clone
+
+	case *ArrayPattern:
+		return &ArrayPattern{Token: n.Token, Elements: cloneIdentifiers(n.Elements), Rest: cloneIdentifier(n.Rest)}
+
+	case *HashPattern:
+		return &HashPattern{Token: n.Token, Keys: cloneIdentifiers(n.Keys)}
+
+	case *RangeExpression:
+		return &RangeExpression{
+			Token:     n.Token,
+			Start:     cloneExpression(n.Start),
+			End:       cloneExpression(n.End),
+			Exclusive: n.Exclusive,
+		}
+	}
+
+	return node
+}
+
+func cloneExpression(expression Expression) Expression {
+	if isNilNode(expression) {
+		return nil
+	}
+	return Clone(expression).(Expression)
+}
+
+func cloneBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return &BlockStatement{Token: block.Token, Statements: cloneStatements(block.Statements)}
+}
+
+func cloneIdentifier(identifier *Identifier) *Identifier {
+	if identifier == nil {
+		return nil
+	}
+	clone := *identifier
+	return &clone
+}
+
+func cloneStatements(statements []Statement) []Statement {
+	if statements == nil {
+		return nil
+	}
+
+	clones := make([]Statement, len(statements))
+	for i, statement := range statements {
+		if !isNilNode(statement) {
+			clones[i] = Clone(statement).(Statement)
+		}
+	}
+	return clones
+}
+
+func cloneExpressions(expressions []Expression) []Expression {
+	if expressions == nil {
+		return nil
+	}
+
+	clones := make([]Expression, len(expressions))
+	for i, expression := range expressions {
+		clones[i] = cloneExpression(expression)
+	}
+	return clones
+}
+
+func cloneIdentifiers(identifiers []*Identifier) []*Identifier {
+	if identifiers == nil {
+		return nil
+	}
+
+	clones := make([]*Identifier, len(identifiers))
+	for i, identifier := range identifiers {
+		clones[i] = cloneIdentifier(identifier)
+	}
+	return clones
+}
@@ -0,0 +1,99 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"testing"
+)
+
+func TestCloneIsEqual(t *testing.T) {
+	input := `
+	import { sub, mul } from "mathlib";
+	export fn twice(x) { x * 2 }
+	export { sub };
+	let add = fn(a, b) { return a + b; };
+	let result = if (add(1, 2) > 2) { [1, 2, 3][0] } else { -1 };
+	let a = 1, b, c = a;
+	let hash = {"one": 1, "two": add(1, 1)};
+	let [head, ...tail] = 1..10;
+	do { break; } while (true);
+	outer: while (true) { inner: while (x) { if (y) { continue inner; } break outer; } };
+	try { risky() } catch (e) { recover(e) };
+	a, b = b, a;
+	let point = struct { x: 1, y: add(1, 1) };
+	for (i, x in [1, 2]) { puts(i, x) };
+	let size = match len(xs) { 0 => "none", 1 | 2 => "few", _ => "many" };
+	puts(size) unless quiet;
+	print size, "done";
+	let evens = xs[::2] + xs[1:n:2];
+	interface Shape { fn area(): Float; fn scale(by: Float, origin): Shape; fn reset() }
+	let bump = fn() { outer count = count + 1; global total = 0 };
+	let gen = fn() { yield 1; yield; };
+	@memoize @trace("fib", level: 2) fn fib(n) { n };
+	let header = b"\x89PNG";
+	if (typeof x == "INTEGER" && y is Str) { 1 };
+	`
+
+	program := parseProgram(t, input)
+	clone := ast.Clone(program)
+
+	if !ast.Equal(program, clone) {
+		t.Fatalf("clone is not equal to original:\n%s\n%s", program.String(), clone.String())
+	}
+}
+
+func TestCloneMutationDoesNotAffectOriginal(t *testing.T) {
+	program := parseProgram(t, `let f = fn(x) { x + 1 }; f(2);`)
+	clone := ast.Clone(program).(*ast.Program)
+
+	ast.Walk(clone, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.InfixExpression:
+			node.Operator = "-"
+		case *ast.Identifier:
+			node.Value = "renamed"
+		case *ast.CallExpression:
+			node.Arguments = append(node.Arguments, &ast.IntegerLiteral{Value: 3})
+		}
+		return true
+	})
+
+	expected := "let f = fn(x)(x + 1);f(2)"
+	if program.String() != expected {
+		t.Errorf("original was mutated. expected=%q, got=%q", expected, program.String())
+	}
+
+	if ast.Equal(program, clone) {
+		t.Errorf("expected mutated clone to differ from original")
+	}
+}
+
+func TestCloneCopiesHashPairs(t *testing.T) {
+	program := parseProgram(t, `{"one": 1, "two": 2}`)
+	original := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+
+	clone := ast.Clone(original).(*ast.HashLiteral)
+
+	for i, pair := range clone.Pairs {
+		if pair.Key == original.Pairs[i].Key || pair.Value == original.Pairs[i].Value {
+			t.Errorf("clone shares pair %d with original", i)
+		}
+		pair.Value.(*ast.IntegerLiteral).Value = 42
+	}
+
+	clone.Pairs = clone.Pairs[:1]
+	clone.Pairs[0].Key = &ast.StringLiteral{Value: "replaced"}
+
+	if len(original.Pairs) != 2 {
+		t.Fatalf("original pairs changed length. got=%d", len(original.Pairs))
+	}
+
+	if original.Pairs[0].Key.(*ast.StringLiteral).Value != "one" {
+		t.Errorf("original key was replaced through clone")
+	}
+
+	for _, pair := range original.Pairs {
+		if pair.Value.(*ast.IntegerLiteral).Value == 42 {
+			t.Errorf("original value was mutated through clone")
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package ast
+
+import (
+	"fmt"
+	"monkey/token"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+// Dump renders node as an indented tree of its types, positions and fields
+// for debugging, like
+//
+//	*ast.InfixExpression 1:3 {
+//	  Left: *ast.IntegerLiteral 1:1 {
+//	    Value: 1
+//	    Suffix: ""
+//	  }
+//	  Operator: "+"
+//	  ...
+//	}
+//
+// The position is the line:column of the node's token. Tokens themselves and
+// empty comments are left out.
+func Dump(node Node) string {
+	var out strings.Builder
+	dumpValue(&out, reflect.ValueOf(node), 0)
+	return out.String()
+}
+
+func dumpValue(out *strings.Builder, v reflect.Value, depth int) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		out.WriteString("nil")
+
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			out.WriteString("nil")
+			return
+		}
+		if v.Kind() == reflect.Pointer {
+			out.WriteString("*")
+		}
+		dumpValue(out, v.Elem(), depth)
+
+	case reflect.Struct:
+		out.WriteString(v.Type().String() + " ")
+		if field := v.FieldByName("Token"); field.IsValid() && field.Type() == tokenType {
+			tok := field.Interface().(token.Token)
+			fmt.Fprintf(out, "%d:%d ", tok.Line, tok.Column)
+		}
+		out.WriteString("{")
+		fields := 0
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || field.Type == tokenType || field.Anonymous && v.Field(i).IsZero() {
+				continue
+			}
+
+			dumpLine(out, depth+1, field.Name+": ")
+			dumpValue(out, v.Field(i), depth+1)
+			fields++
+		}
+		dumpClose(out, depth, fields, "}")
+
+	case reflect.Slice:
+		out.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			dumpLine(out, depth+1, "")
+			dumpValue(out, v.Index(i), depth+1)
+		}
+		dumpClose(out, depth, v.Len(), "]")
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+
+		out.WriteString("map[")
+		for _, key := range keys {
+			dumpLine(out, depth+1, "")
+			dumpValue(out, key, depth+1)
+			out.WriteString(": ")
+			dumpValue(out, v.MapIndex(key), depth+1)
+		}
+		dumpClose(out, depth, len(keys), "]")
+
+	case reflect.String:
+		out.WriteString(strconv.Quote(v.String()))
+
+	default:
+		fmt.Fprint(out, v.Interface())
+	}
+}
+
+func dumpLine(out *strings.Builder, depth int, prefix string) {
+	out.WriteString("\n" + strings.Repeat("  ", depth) + prefix)
+}
+
+// dumpClose closes a struct, slice or map, on its own line unless it is empty.
+func dumpClose(out *strings.Builder, depth int, count int, closing string) {
+	if count > 0 {
+		dumpLine(out, depth, "")
+	}
+	out.WriteString(closing)
+}
@@ -0,0 +1,52 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"strings"
+	"testing"
+)
+
+func TestDumpInfixExpression(t *testing.T) {
+	program := parseProgram(t, "1 + 2")
+	dump := ast.Dump(program.Statements[0].(*ast.ExpressionStatement).Expression)
+
+	expected := `*ast.InfixExpression 1:3 {
+  Left: *ast.IntegerLiteral 1:1 {
+    Value: 1
+    Suffix: ""
+  }
+  Operator: "+"
+  Right: *ast.IntegerLiteral 1:5 {
+    Value: 2
+    Suffix: ""
+  }
+}`
+
+	if dump != expected {
+		t.Errorf("Dump() wrong. expected=\n%s\ngot=\n%s", expected, dump)
+	}
+}
+
+func TestDumpCollections(t *testing.T) {
+	program := parseProgram(t, `let h = {"a": [1, x]}; f();`)
+	dump := ast.Dump(program)
+
+	for _, expected := range []string{
+		"*ast.LetStatement 1:1 {",
+		"Pairs: [\n",
+		"ast.HashPair {",
+		`Key: *ast.StringLiteral 1:10 {`,
+		`Value: "a"`,
+		"Elements: [\n",
+		`Value: "x"`,
+		"Arguments: []",
+	} {
+		if !strings.Contains(dump, expected) {
+			t.Errorf("Dump() does not contain %q:\n%s", expected, dump)
+		}
+	}
+
+	if ast.Dump(nil) != "nil" {
+		t.Errorf("Dump(nil) wrong. got=%q", ast.Dump(nil))
+	}
+}
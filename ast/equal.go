@@ -0,0 +1,360 @@
+package ast
+
+import "bytes"
+
+// Equal reports whether a and b are structurally equal. Operators, literal
+// values and child order are compared, token details are ignored. Hash
+// literal pairs are compared regardless of their order.
+func Equal(a, b Node) bool {
+	if isNilNode(a) || isNilNode(b) {
+		return isNilNode(a) && isNilNode(b)
+	}
+
+	switch a := a.(type) {
+	case *Program:
+		b, ok := b.(*Program)
+		return ok && equalNodes(a.Statements, b.Statements)
+
+	case *LetStatement:
+		b, ok := b.(*LetStatement)
+		return ok && a.IsConst == b.IsConst && Equal(a.Name, b.Name) && Equal(a.Pattern, b.Pattern) &&
+			Equal(a.Value, b.Value) && equalBindings(a.Additional, b.Additional)
+
+	case *AssignStatement:
+		b, ok := b.(*AssignStatement)
+		return ok && Equal(a.Target, b.Target) && Equal(a.Value, b.Value)
+
+	case *ScopedAssignStatement:
+		b, ok := b.(*ScopedAssignStatement)
+		return ok && a.Scope == b.Scope && Equal(a.Target, b.Target) && Equal(a.Value, b.Value)
+
+	case *AssignExpression:
+		b, ok := b.(*AssignExpression)
+		return ok && Equal(a.Target, b.Target) && Equal(a.Value, b.Value)
+
+	case *MultiAssignStatement:
+		b, ok := b.(*MultiAssignStatement)
+		return ok && equalNodes(a.Targets, b.Targets) && equalNodes(a.Values, b.Values)
+
+	case *ReturnStatement:
+		b, ok := b.(*ReturnStatement)
+		return ok && Equal(a.ReturnValue, b.ReturnValue)
+
+	case *PrintStatement:
+		b, ok := b.(*PrintStatement)
+		return ok && equalNodes(a.Args, b.Args)
+
+	case *GuardedStatement:
+		b, ok := b.(*GuardedStatement)
+		return ok && a.Negate == b.Negate && Equal(a.Statement, b.Statement) && Equal(a.Condition, b.Condition)
+
+	case *ExpressionStatement:
+		b, ok := b.(*ExpressionStatement)
+		return ok && Equal(a.Expression, b.Expression)
+
+	case *ImportStatement:
+		b, ok := b.(*ImportStatement)
+		return ok && a.Path == b.Path && (a.Names == nil) == (b.Names == nil) && equalNodes(a.Names, b.Names)
+
+	case *ExportStatement:
+		b, ok := b.(*ExportStatement)
+		return ok && Equal(a.Declaration, b.Declaration) && equalNodes(a.Names, b.Names)
+
+	case *InterfaceDeclaration:
+		b, ok := b.(*InterfaceDeclaration)
+		return ok && Equal(a.Name, b.Name) && equalMethods(a.Methods, b.Methods)
+
+	case *YieldStatement:
+		b, ok := b.(*YieldStatement)
+		return ok && Equal(a.Value, b.Value)
+
+	case *BreakStatement:
+		b, ok := b.(*BreakStatement)
+		return ok && Equal(a.Label, b.Label)
+
+	case *ContinueStatement:
+		b, ok := b.(*ContinueStatement)
+		return ok && Equal(a.Label, b.Label)
+
+	case *DecoratedStatement:
+		b, ok := b.(*DecoratedStatement)
+		if !ok || len(a.Decorators) != len(b.Decorators) {
+			return false
+		}
+		for i := range a.Decorators {
+			if !Equal(a.Decorators[i].Expression, b.Decorators[i].Expression) {
+				return false
+			}
+		}
+		return Equal(a.Declaration, b.Declaration)
+
+	case *LabeledStatement:
+		b, ok := b.(*LabeledStatement)
+		return ok && Equal(a.Label, b.Label) && Equal(a.Statement, b.Statement)
+
+	case *BlockStatement:
+		b, ok := b.(*BlockStatement)
+		return ok && equalNodes(a.Statements, b.Statements)
+
+	case *Identifier:
+		b, ok := b.(*Identifier)
+		return ok && a.Value == b.Value
+
+	case *IntegerLiteral:
+		b, ok := b.(*IntegerLiteral)
+		return ok && a.Value == b.Value && a.Suffix == b.Suffix
+
+	case *FloatLiteral:
+		b, ok := b.(*FloatLiteral)
+		return ok && a.Value == b.Value && a.Suffix == b.Suffix
+
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+
+	case *StringLiteral:
+		b, ok := b.(*StringLiteral)
+		return ok && a.Value == b.Value
+
+	case *BytesLiteral:
+		b, ok := b.(*BytesLiteral)
+		return ok && bytes.Equal(a.Value, b.Value)
+
+	case *RegexLiteral:
+		b, ok := b.(*RegexLiteral)
+		return ok && a.Pattern == b.Pattern && a.Flags == b.Flags
+
+	case *PrefixExpression:
+		b, ok := b.(*PrefixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Right, b.Right)
+
+	case *InfixExpression:
+		b, ok := b.(*InfixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Left, b.Left) && Equal(a.Right, b.Right)
+
+	case *IfExpression:
+		b, ok := b.(*IfExpression)
+		return ok && Equal(a.Condition, b.Condition) &&
+			Equal(a.Consequence, b.Consequence) && Equal(a.Alternative, b.Alternative)
+
+	case *UnlessExpression:
+		b, ok := b.(*UnlessExpression)
+		return ok && Equal(a.Condition, b.Condition) &&
+			Equal(a.Consequence, b.Consequence) && Equal(a.Alternative, b.Alternative)
+
+	case *WhileExpression:
+		b, ok := b.(*WhileExpression)
+		return ok && Equal(a.Condition, b.Condition) && Equal(a.Body, b.Body)
+
+	case *ForInExpression:
+		b, ok := b.(*ForInExpression)
+		return ok && Equal(a.Index, b.Index) && Equal(a.Var, b.Var) && Equal(a.Iterable, b.Iterable) &&
+			Equal(a.Body, b.Body)
+
+	case *DoWhileExpression:
+		b, ok := b.(*DoWhileExpression)
+		return ok && Equal(a.Body, b.Body) && Equal(a.Condition, b.Condition)
+
+	case *TryExpression:
+		b, ok := b.(*TryExpression)
+		return ok && Equal(a.Body, b.Body) && Equal(a.Binding, b.Binding) && Equal(a.Handler, b.Handler)
+
+	case *BlockExpression:
+		b, ok := b.(*BlockExpression)
+		return ok && Equal(a.Block, b.Block)
+
+	case *MatchExpression:
+		b, ok := b.(*MatchExpression)
+		return ok && Equal(a.Subject, b.Subject) && equalArms(a.Arms, b.Arms)
+
+	case *FunctionLiteral:
+		b, ok := b.(*FunctionLiteral)
+		return ok && Equal(a.Name, b.Name) && equalNodes(a.Parameters, b.Parameters) && Equal(a.Body, b.Body)
+
+	case *MacroLiteral:
+		b, ok := b.(*MacroLiteral)
+		return ok && equalNodes(a.Parameters, b.Parameters) && Equal(a.Body, b.Body)
+
+	case *CallExpression:
+		b, ok := b.(*CallExpression)
+		return ok && Equal(a.Function, b.Function) && equalNodes(a.Arguments, b.Arguments) &&
+			equalNodes(a.NamedArguments, b.NamedArguments)
+
+	case *NamedArgument:
+		b, ok := b.(*NamedArgument)
+		return ok && Equal(a.Name, b.Name) && Equal(a.Value, b.Value)
+
+	case *ArrayLiteral:
+		b, ok := b.(*ArrayLiteral)
+		return ok && equalNodes(a.Elements, b.Elements)
+
+	case *ListComprehension:
+		b, ok := b.(*ListComprehension)
+		return ok && Equal(a.Element, b.Element) && Equal(a.Var, b.Var) &&
+			Equal(a.Iterable, b.Iterable) && Equal(a.Filter, b.Filter)
+
+	case *TupleLiteral:
+		b, ok := b.(*TupleLiteral)
+		return ok && equalNodes(a.Elements, b.Elements)
+
+	case *DotExpression:
+		b, ok := b.(*DotExpression)
+		return ok && Equal(a.Left, b.Left) && Equal(a.Property, b.Property)
+
+	case *OptionalIndexExpression:
+		b, ok := b.(*OptionalIndexExpression)
+		return ok && a.Computed == b.Computed && Equal(a.Left, b.Left) && Equal(a.Index, b.Index)
+
+	case *NonNullAssertion:
+		b, ok := b.(*NonNullAssertion)
+		return ok && Equal(a.Left, b.Left)
+
+	case *TypeofExpression:
+		b, ok := b.(*TypeofExpression)
+		return ok && Equal(a.Value, b.Value)
+
+	case *TypeCheckExpression:
+		b, ok := b.(*TypeCheckExpression)
+		return ok && Equal(a.Value, b.Value) && Equal(a.TypeName, b.TypeName)
+
+	case *SpreadElement:
+		b, ok := b.(*SpreadElement)
+		return ok && Equal(a.Value, b.Value)
+
+	case *IndexExpression:
+		b, ok := b.(*IndexExpression)
+		return ok && Equal(a.Left, b.Left) && Equal(a.Index, b.Index)
+
+	case *SliceExpression:
+		b, ok := b.(*SliceExpression)
+		return ok && Equal(a.Left, b.Left) && Equal(a.Start, b.Start) && Equal(a.Stop, b.Stop) && Equal(a.Step, b.Step)
+
+	case *HashLiteral:
+		b, ok := b.(*HashLiteral)
+		return ok && equalPairs(a.Pairs, b.Pairs)
+
+	case *StructLiteral:
+		b, ok := b.(*StructLiteral)
+		return ok && equalFields(a.Fields, b.Fields)
+
+	case *ArrayPattern:
+		b, ok := b.(*ArrayPattern)
+		return ok && equalNodes(a.Elements, b.Elements) && Equal(a.Rest, b.Rest)
+
+	case *HashPattern:
+		b, ok := b.(*HashPattern)
+		return ok && equalNodes(a.Keys, b.Keys)
+
+	case *RangeExpression:
+		b, ok := b.(*RangeExpression)
+		return ok && a.Exclusive == b.Exclusive && Equal(a.Start, b.Start) && Equal(a.End, b.End)
+	}
+
+	return false
+}
+
+func equalNodes[T Node](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalBindings(a, b []LetBinding) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !Equal(a[i].Name, b[i].Name) || !Equal(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalArms(a, b []MatchArm) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !equalNodes(a[i].Patterns, b[i].Patterns) || !Equal(a[i].Result, b[i].Result) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalMethods(a, b []MethodSignature) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !Equal(a[i].Name, b[i].Name) || !Equal(a[i].ReturnType, b[i].ReturnType) ||
+			len(a[i].Parameters) != len(b[i].Parameters) {
+			return false
+		}
+
+		for j, parameter := range a[i].Parameters {
+			other := b[i].Parameters[j]
+			if !Equal(parameter.Name, other.Name) || !Equal(parameter.Type, other.Type) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func equalFields(a, b []StructField) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !Equal(a[i].Name, b[i].Name) || !Equal(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalPairs(a, b []HashPair) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	matched := make([]bool, len(b))
+	for _, pairA := range a {
+		found := false
+		for i, pairB := range b {
+			if matched[i] {
+				continue
+			}
+
+			if Equal(pairA.Key, pairB.Key) && Equal(pairA.Value, pairB.Value) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
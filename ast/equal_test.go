@@ -0,0 +1,110 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"testing"
+)
+
+func TestEqualIndependentlyParsedPrograms(t *testing.T) {
+	input := `
+	let add = fn(a, b) { return a + b; };
+	let result = if (add(1, 2) > 2) { [1, 2, 3][0] } else { -1 };
+	let hash = {"one": 1, "two": add(1, 1), true: "yes"};
+	unless (result) { add(...[1, 2]) }
+	while (x < 10) { break; }
+	let [head, ...tail] = 1..<10;
+	`
+
+	a := parseProgram(t, input)
+	b := parseProgram(t, input)
+
+	if !ast.Equal(a, b) {
+		t.Errorf("expected programs to be equal:\n%s\n%s", a.String(), b.String())
+	}
+}
+
+func TestEqualIgnoresHashPairOrder(t *testing.T) {
+	a := parseProgram(t, `{"one": 1, "two": 2, "three": 3}`)
+	b := parseProgram(t, `{"three": 3, "one": 1, "two": 2}`)
+
+	if !ast.Equal(a, b) {
+		t.Errorf("expected hash literals to be equal")
+	}
+}
+
+func TestEqualDetectsDifferences(t *testing.T) {
+	tests := []struct {
+		a string
+		b string
+	}{
+		{"1 + 2", "1 - 2"},
+		{"5i", "5u"},
+		{"3.0f", "3.0"},
+		{"1 + 2", "1 + 3"},
+		{"a + b", "b + a"},
+		{"let x = 5;", "let y = 5;"},
+		{"let x = 5;", "const x = 5;"},
+		{"let x = 5, y = 6;", "let x = 5, y = 7;"},
+		{`"foo"`, `"bar"`},
+		{"true", "false"},
+		{"f(1, 2)", "f(2, 1)"},
+		{"f(1, 2)", "f(1)"},
+		{"fn(x) { x }", "fn(y) { x }"},
+		{"fn f(x) { x }", "fn(x) { x }"},
+		{"if (x) { 1 }", "if (x) { 1 } else { 2 }"},
+		{"[1, 2, 3]", "[1, 2, 4]"},
+		{"(1, 2)", "[1, 2]"},
+		{"[x for x in xs]", "[x for x in xs if x]"},
+		{`{"a": 1}`, `{"a": 2}`},
+		{"1..10", "1..<10"},
+		{"x[0]", "x[1]"},
+		{"a?.b", "a?.[b]"},
+		{"1; 2", "1"},
+		{`import "a";`, `import { x } from "a";`},
+		{"while (x) { break a; }", "while (x) { break b; }"},
+		{"while (x) { break; }", "while (x) { continue; }"},
+		{"a, b = b, a;", "a, b = a, b;"},
+		{"struct { x: 1 }", `{"x": 1}`},
+		{"struct { x: 1, y: 2 }", "struct { y: 2, x: 1 }"},
+		{"match x { 1 | 2 => a }", "match x { 1 => a, 2 => a }"},
+		{"for (i, x in xs) {}", "for (x in xs) {}"},
+		{"return x if a;", "return x unless a;"},
+		{"print a, b;", "print a;"},
+		{"f(a = b)", "f(b = a)"},
+		{"x[1:2]", "x[1:2:1]"},
+		{"x[:2]", "x[2:]"},
+		{"interface A { fn f(x: Int) }", "interface A { fn f(x) }"},
+		{"interface A { fn f(): Int }", "interface A { fn f() }"},
+		{"outer x = 1;", "global x = 1;"},
+		{"outer x = 1;", "x = 1;"},
+		{"yield x;", "yield;"},
+		{"x is Int", "x is Str"},
+		{"typeof x", "typeof y"},
+		{`b"a"`, `b"b"`},
+		{`b"a"`, `"a"`},
+		{"@a fn f() {}", "@b fn f() {}"},
+		{"@a fn f() {}", "@a() fn f() {}"},
+		{"@a fn f() {}", "@a @a fn f() {}"},
+	}
+
+	for _, tt := range tests {
+		a := parseProgram(t, tt.a)
+		b := parseProgram(t, tt.b)
+
+		if ast.Equal(a, b) {
+			t.Errorf("expected %q and %q to differ", tt.a, tt.b)
+		}
+	}
+}
+
+func TestEqualNil(t *testing.T) {
+	var nilBlock *ast.BlockStatement
+
+	if !ast.Equal(nil, nilBlock) {
+		t.Errorf("expected nil and typed nil to be equal")
+	}
+
+	if ast.Equal(nil, &ast.Program{}) {
+		t.Errorf("expected nil and non-nil to differ")
+	}
+}
@@ -0,0 +1,221 @@
+package ast
+
+// Builtins are the names of the evaluator's builtin functions, which are
+// never reported as free variables.
+var Builtins = []string{"len", "first", "last", "rest", "push", "puts"}
+
+// FreeVariables returns the names of the identifiers that are used without
+// being bound by a let, a function parameter or another binding in scope, in
+// the order they are first used. Builtins are not reported.
+//
+// Function bodies are resolved once the enclosing block is complete, so a
+// function may refer to bindings declared after it, like Monkey's closures
+// do at runtime.
+func (p *Program) FreeVariables() []string {
+	r := &resolver{scope: newScope(nil), seen: map[string]bool{}}
+	for _, name := range Builtins {
+		r.scope.bind(name)
+	}
+
+	r.scope = newScope(r.scope)
+	for _, statement := range p.Statements {
+		r.walk(statement)
+	}
+	r.popScope()
+
+	return r.free
+}
+
+type scope struct {
+	names   map[string]bool
+	parent  *scope
+	pending []Node // function bodies resolved when the scope ends
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{names: map[string]bool{}, parent: parent}
+}
+
+func (s *scope) bind(name string) {
+	s.names[name] = true
+}
+
+func (s *scope) isBound(name string) bool {
+	for ; s != nil; s = s.parent {
+		if s.names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+type resolver struct {
+	scope *scope
+	free  []string
+	seen  map[string]bool
+}
+
+func (r *resolver) walk(node Node) {
+	Walk(node, r.visit)
+}
+
+func (r *resolver) pushScope() {
+	r.scope = newScope(r.scope)
+}
+
+// popScope resolves the functions declared in the current scope and leaves
+// it.
+func (r *resolver) popScope() {
+	for len(r.scope.pending) > 0 {
+		function := r.scope.pending[0]
+		r.scope.pending = r.scope.pending[1:]
+		r.resolveFunction(function)
+	}
+	r.scope = r.scope.parent
+}
+
+func (r *resolver) resolveFunction(function Node) {
+	r.pushScope()
+
+	switch function := function.(type) {
+	case *FunctionLiteral:
+		r.bindIdentifiers(function.Name)
+		r.bindIdentifiers(function.Parameters...)
+		r.walk(function.Body)
+	case *MacroLiteral:
+		r.bindIdentifiers(function.Parameters...)
+		r.walk(function.Body)
+	}
+
+	r.popScope()
+}
+
+func (r *resolver) bindIdentifiers(identifiers ...*Identifier) {
+	for _, identifier := range identifiers {
+		if identifier != nil {
+			r.scope.bind(identifier.Value)
+		}
+	}
+}
+
+func (r *resolver) bindPattern(pattern Expression) {
+	switch pattern := pattern.(type) {
+	case *ArrayPattern:
+		r.bindIdentifiers(pattern.Elements...)
+		r.bindIdentifiers(pattern.Rest)
+	case *HashPattern:
+		r.bindIdentifiers(pattern.Keys...)
+	}
+}
+
+// visit handles the nodes that bind names or hold identifiers which are not
+// variable references and lets Walk descend into all others.
+func (r *resolver) visit(node Node) bool {
+	switch n := node.(type) {
+	case *Identifier:
+		if n.Value != "_" && !r.scope.isBound(n.Value) && !r.seen[n.Value] {
+			r.seen[n.Value] = true
+			r.free = append(r.free, n.Value)
+		}
+
+	case *BlockStatement:
+		r.pushScope()
+		for _, statement := range n.Statements {
+			r.walk(statement)
+		}
+		r.popScope()
+
+	case *FunctionLiteral, *MacroLiteral:
+		r.scope.pending = append(r.scope.pending, n)
+
+	case *LetStatement:
+		r.walk(n.Value)
+		r.bindIdentifiers(n.Name)
+		r.bindPattern(n.Pattern)
+		for _, binding := range n.Additional {
+			r.walk(binding.Value)
+			r.bindIdentifiers(binding.Name)
+		}
+
+	case *ImportStatement:
+		r.bindIdentifiers(n.Names...)
+
+	case *ExportStatement:
+		r.walk(n.Declaration)
+		for _, name := range n.Names {
+			r.walk(name)
+		}
+
+	case *InterfaceDeclaration:
+		r.bindIdentifiers(n.Name)
+
+	case *LabeledStatement:
+		r.walk(n.Statement)
+
+	case *BreakStatement, *ContinueStatement:
+		// labels are not variables
+
+	case *ForInExpression:
+		r.walk(n.Iterable)
+		r.pushScope()
+		r.bindIdentifiers(n.Index, n.Var)
+		r.walk(n.Body)
+		r.popScope()
+
+	case *ListComprehension:
+		r.walk(n.Iterable)
+		r.pushScope()
+		r.bindIdentifiers(n.Var)
+		r.walk(n.Filter)
+		r.walk(n.Element)
+		r.popScope()
+
+	case *TryExpression:
+		r.walk(n.Body)
+		r.pushScope()
+		r.bindIdentifiers(n.Binding)
+		r.walk(n.Handler)
+		r.popScope()
+
+	case *MatchExpression:
+		r.walk(n.Subject)
+		for _, arm := range n.Arms {
+			r.pushScope()
+			r.bindIdentifiers(arm.Bindings()...)
+			for _, pattern := range arm.Patterns {
+				switch pattern.(type) {
+				case *ArrayPattern, *HashPattern:
+				default:
+					r.walk(pattern)
+				}
+			}
+			r.walk(arm.Result)
+			r.popScope()
+		}
+
+	case *DotExpression:
+		r.walk(n.Left)
+
+	case *OptionalIndexExpression:
+		r.walk(n.Left)
+		if n.Computed {
+			r.walk(n.Index)
+		}
+
+	case *NamedArgument:
+		r.walk(n.Value)
+
+	case *StructLiteral:
+		for _, field := range n.Fields {
+			r.walk(field.Value)
+		}
+
+	case *TypeCheckExpression:
+		r.walk(n.Value)
+
+	default:
+		return true
+	}
+
+	return false
+}
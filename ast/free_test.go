@@ -0,0 +1,53 @@
+package ast_test
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFreeVariables(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"x", []string{"x"}},
+		{"let x = 1; x", []string{}},
+		{"x; let x = 1;", []string{"x"}},
+		{"let x = x + 1;", []string{"x"}},
+		{"let f = fn(x) { x + y }; f(1)", []string{"y"}},
+		{"let x = 1; let f = fn(x) { x }; x", []string{}},
+		{"let f = fn(a) { a }; a", []string{"a"}},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };", []string{}},
+		{"let even = fn(n) { odd(n) }; let odd = fn(n) { even(n) };", []string{}},
+		{"fn loop(n) { loop(n) }; loop", []string{"loop"}},
+		{"if (a) { let b = 1; b } else { b }", []string{"a", "b"}},
+		{"len(puts)", []string{}},
+		{"let [head, ...tail] = xs; head + tail", []string{"xs"}},
+		{"let {name} = person; name", []string{"person"}},
+		{"let a = 1, b = a; b + c", []string{"c"}},
+		{"for (i, x in xs) { i + x + total }", []string{"xs", "total"}},
+		{"[x * k for x in xs if x > min]", []string{"xs", "min", "k"}},
+		{"try { risky() } catch (e) { e }", []string{"risky"}},
+		{"match p { [a, b] => a + b, {x} => x, limit => c, _ => 0 }", []string{"p", "limit", "c"}},
+		{"obj.name; obj?.field; f(key: value)", []string{"obj", "f", "value"}},
+		{"struct { x: y }", []string{"y"}},
+		{"let x = 1; x is Int", []string{}},
+		{"y is Str", []string{"y"}},
+		{"outer: while (run) { break outer; }", []string{"run"}},
+		{`import { sub } from "math"; sub(1)`, []string{}},
+		{"x; x; y", []string{"x", "y"}},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+
+		free := program.FreeVariables()
+		if free == nil {
+			free = []string{}
+		}
+
+		if !reflect.DeepEqual(free, tt.expected) {
+			t.Errorf("FreeVariables() of %q wrong. expected=%v, got=%v", tt.input, tt.expected, free)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Binding strength of the operators, mirroring the parser's precedences.
+const (
+	precLowest = iota
+	precAssign
+	precPipe
+	precOr
+	precCoalesce
+	precAnd
+	precRange
+	precEquals
+	precLessGreater
+	precSum
+	precProduct
+	precPrefix
+	precAtom // literals, calls, index and dot expressions
+)
+
+var operatorPrecedences = map[string]int{
+	"|>": precPipe,
+	"||": precOr,
+	"??": precCoalesce,
+	"&&": precAnd,
+	"==": precEquals,
+	"!=": precEquals,
+	"<":  precLessGreater,
+	">":  precLessGreater,
+	"+":  precSum,
+	"-":  precSum,
+	"<>": precSum,
+	"*":  precProduct,
+	"/":  precProduct,
+}
+
+// SourceString renders node as source code with only the parentheses needed
+// to keep its structure, so `(1 + 2) * 3` stays as is while String() would
+// give `((1 + 2) * 3)`. Statements are separated by semicolons. Nodes without
+// operator children fall back to their String method.
+func SourceString(node Node) string {
+	if isNilNode(node) {
+		return ""
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		return joinStatements(n.Statements, ";\n")
+
+	case *BlockStatement:
+		if len(n.Statements) == 0 {
+			return "{}"
+		}
+		return "{ " + joinStatements(n.Statements, "; ") + " }"
+
+	case *ExpressionStatement:
+		return SourceString(n.Expression)
+
+	case *LetStatement:
+		var out strings.Builder
+
+		out.WriteString(n.TokenLiteral() + " ")
+		if n.Pattern != nil {
+			out.WriteString(n.Pattern.String())
+		} else {
+			out.WriteString(n.Name.String())
+		}
+		writeInitializer(&out, n.Value)
+
+		for _, binding := range n.Additional {
+			out.WriteString(", " + binding.Name.String())
+			writeInitializer(&out, binding.Value)
+		}
+
+		return out.String()
+
+	case *ReturnStatement:
+		if n.ReturnValue == nil {
+			return "return"
+		}
+		return "return " + SourceString(n.ReturnValue)
+
+	case *DecoratedStatement:
+		var out strings.Builder
+		for _, decorator := range n.Decorators {
+			out.WriteString("@" + SourceString(decorator.Expression) + "\n")
+		}
+		out.WriteString(SourceString(n.Declaration))
+		return out.String()
+
+	case *YieldStatement:
+		if n.Value == nil {
+			return "yield"
+		}
+		return "yield " + SourceString(n.Value)
+
+	case *PrintStatement:
+		if len(n.Args) == 0 {
+			return n.TokenLiteral()
+		}
+		return n.TokenLiteral() + " " + joinExpressions(n.Args)
+
+	case *GuardedStatement:
+		return SourceString(n.Statement) + " " + n.TokenLiteral() + " " + SourceString(n.Condition)
+
+	case *AssignStatement:
+		return SourceString(n.Target) + " = " + SourceString(n.Value)
+
+	case *ScopedAssignStatement:
+		return n.TokenLiteral() + " " + SourceString(n.Target) + " = " + SourceString(n.Value)
+
+	case *MultiAssignStatement:
+		return joinExpressions(n.Targets) + " = " + joinExpressions(n.Values)
+
+	case *InfixExpression:
+		precedence := expressionPrecedence(n)
+		left := operand(n.Left, expressionPrecedence(n.Left) < precedence)
+		right := operand(n.Right, expressionPrecedence(n.Right) <= precedence)
+		return left + " " + n.Operator + " " + right
+
+	case *AssignExpression:
+		left := operand(n.Target, expressionPrecedence(n.Target) <= precAssign)
+		right := operand(n.Value, expressionPrecedence(n.Value) < precAssign)
+		return left + " = " + right
+
+	case *RangeExpression:
+		left := operand(n.Start, expressionPrecedence(n.Start) < precRange)
+		right := operand(n.End, expressionPrecedence(n.End) <= precRange)
+		return left + n.Token.Literal + right
+
+	case *PrefixExpression:
+		return n.Operator + operand(n.Right, expressionPrecedence(n.Right) < precPrefix)
+
+	case *TypeofExpression:
+		return "typeof " + operand(n.Value, expressionPrecedence(n.Value) < precPrefix)
+
+	case *TypeCheckExpression:
+		return operand(n.Value, expressionPrecedence(n.Value) < precLessGreater) + " is " + n.TypeName.String()
+
+	case *CallExpression:
+		arguments := joinExpressions(n.Arguments)
+		if len(n.NamedArguments) > 0 {
+			named := []string{}
+			for _, argument := range n.NamedArguments {
+				named = append(named, SourceString(argument))
+			}
+			if arguments != "" {
+				arguments += ", "
+			}
+			arguments += strings.Join(named, ", ")
+		}
+		return postfixOperand(n.Function) + "(" + arguments + ")"
+
+	case *NamedArgument:
+		return n.Name.String() + ": " + SourceString(n.Value)
+
+	case *IndexExpression:
+		return postfixOperand(n.Left) + "[" + SourceString(n.Index) + "]"
+
+	case *SliceExpression:
+		out := postfixOperand(n.Left) + "["
+		if n.Start != nil {
+			out += SourceString(n.Start)
+		}
+		out += ":"
+		if n.Stop != nil {
+			out += SourceString(n.Stop)
+		}
+		if n.Step != nil {
+			out += ":" + SourceString(n.Step)
+		}
+		return out + "]"
+
+	case *DotExpression:
+		return postfixOperand(n.Left) + "." + n.Property.String()
+
+	case *OptionalIndexExpression:
+		if n.Computed {
+			return postfixOperand(n.Left) + "?.[" + SourceString(n.Index) + "]"
+		}
+		return postfixOperand(n.Left) + "?." + n.Index.String()
+
+	case *NonNullAssertion:
+		return postfixOperand(n.Left) + "!"
+
+	case *SpreadElement:
+		return "..." + SourceString(n.Value)
+
+	case *ArrayLiteral:
+		return "[" + joinExpressions(n.Elements) + "]"
+
+	case *ListComprehension:
+		out := "[" + SourceString(n.Element) + " for " + n.Var.String() + " in " + SourceString(n.Iterable)
+		if n.Filter != nil {
+			out += " if " + SourceString(n.Filter)
+		}
+		return out + "]"
+
+	case *TupleLiteral:
+		return "(" + joinExpressions(n.Elements) + ")"
+
+	case *HashLiteral:
+		pairs := []string{}
+		for _, pair := range n.Pairs {
+			pairs = append(pairs, SourceString(pair.Key)+": "+SourceString(pair.Value))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+
+	case *StringLiteral:
+		return quoteString(n.Value)
+
+	case *IfExpression:
+		out := "if (" + SourceString(n.Condition) + ") " + SourceString(n.Consequence)
+		if n.Alternative != nil {
+			out += " else " + SourceString(n.Alternative)
+		}
+		return out
+
+	case *UnlessExpression:
+		out := "unless (" + SourceString(n.Condition) + ") " + SourceString(n.Consequence)
+		if n.Alternative != nil {
+			out += " else " + SourceString(n.Alternative)
+		}
+		return out
+
+	case *WhileExpression:
+		return "while (" + SourceString(n.Condition) + ") " + SourceString(n.Body)
+
+	case *DoWhileExpression:
+		return "do " + SourceString(n.Body) + " while (" + SourceString(n.Condition) + ")"
+
+	case *TryExpression:
+		out := "try " + SourceString(n.Body) + " catch "
+		if n.Binding != nil {
+			out += "(" + n.Binding.String() + ") "
+		}
+		return out + SourceString(n.Handler)
+
+	case *ForInExpression:
+		variables := n.Var.String()
+		if n.Index != nil {
+			variables = n.Index.String() + ", " + variables
+		}
+		return "for (" + variables + " in " + SourceString(n.Iterable) + ") " + SourceString(n.Body)
+
+	case *FunctionLiteral:
+		var out strings.Builder
+
+		out.WriteString(n.TokenLiteral())
+		if n.Name != nil {
+			out.WriteString(" " + n.Name.String())
+		}
+
+		parameters := []string{}
+		for _, parameter := range n.Parameters {
+			parameters = append(parameters, parameter.String())
+		}
+		fmt.Fprintf(&out, "(%s) %s", strings.Join(parameters, ", "), SourceString(n.Body))
+
+		return out.String()
+
+	case *BlockExpression:
+		return SourceString(n.Block)
+
+	case Statement:
+		return strings.TrimSuffix(n.String(), ";")
+
+	default:
+		return node.String()
+	}
+}
+
+// expressionPrecedence returns how strongly expression binds its operands,
+// precAtom for expressions that are not operators.
+func expressionPrecedence(expression Expression) int {
+	switch expression := expression.(type) {
+	case *InfixExpression:
+		if precedence, ok := operatorPrecedences[expression.Operator]; ok {
+			return precedence
+		}
+		return precLowest
+	case *AssignExpression:
+		return precAssign
+	case *RangeExpression:
+		return precRange
+	case *PrefixExpression, *TypeofExpression:
+		return precPrefix
+	case *TypeCheckExpression:
+		return precLessGreater
+	default:
+		return precAtom
+	}
+}
+
+func operand(expression Expression, parenthesize bool) string {
+	if parenthesize {
+		return "(" + SourceString(expression) + ")"
+	}
+	return SourceString(expression)
+}
+
+// postfixOperand renders the left side of a call, index or dot expression,
+// which binds tighter than any operator.
+func postfixOperand(expression Expression) string {
+	return operand(expression, expressionPrecedence(expression) < precAtom)
+}
+
+func writeInitializer(out *strings.Builder, value Expression) {
+	if value != nil {
+		out.WriteString(" = " + SourceString(value))
+	}
+}
+
+func joinStatements(statements []Statement, separator string) string {
+	rendered := []string{}
+	for _, statement := range statements {
+		rendered = append(rendered, SourceString(statement))
+	}
+	return strings.Join(rendered, separator)
+}
+
+func joinExpressions(expressions []Expression) string {
+	rendered := []string{}
+	for _, expression := range expressions {
+		rendered = append(rendered, SourceString(expression))
+	}
+	return strings.Join(rendered, ", ")
+}
+
+// quoteString renders value as a double-quoted string literal using the
+// escapes the lexer understands.
+func quoteString(value string) string {
+	return quote(value, false)
+}
+
+// quoteBytes renders value as a `b"..."` literal, escaping every byte that
+// is not printable ASCII.
+func quoteBytes(value []byte) string {
+	return "b" + quote(string(value), true)
+}
+
+func quote(value string, asciiOnly bool) string {
+	var out strings.Builder
+
+	out.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch ch := value[i]; ch {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(ch)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			if ch < 0x20 || ch == 0x7f || asciiOnly && ch > 0x7f {
+				fmt.Fprintf(&out, `\x%02x`, ch)
+			} else {
+				out.WriteByte(ch)
+			}
+		}
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
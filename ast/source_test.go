@@ -0,0 +1,104 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"testing"
+)
+
+func TestSourceStringMinimalParens(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 + 3", "1 + 2 + 3"},
+		{"a <> (b <> c)", "a <> (b <> c)"},
+		{"1 + 2 * 3", "1 + 2 * 3"},
+		{"(1 + 2) * 3", "(1 + 2) * 3"},
+		{"1 - (2 - 3)", "1 - (2 - 3)"},
+		{"(1 - 2) - 3", "1 - 2 - 3"},
+		{"a * (b / c)", "a * (b / c)"},
+		{"-(a + b)", "-(a + b)"},
+		{"-a * b", "-a * b"},
+		{"!(a == b)", "!(a == b)"},
+		{"a < b == (c > d)", "a < b == c > d"},
+		{"(a == b) < c", "(a == b) < c"},
+		{"(a || b) && c", "(a || b) && c"},
+		{"a || b && c", "a || b && c"},
+		{"(a + b)(1)", "(a + b)(1)"},
+		{"(-a)[0]", "(-a)[0]"},
+		{"-a[0]", "-a[0]"},
+		{"f(a + b, (c))[1 * 2].d", "f(a + b, c)[1 * 2].d"},
+		{"(1 + 2)..(3 * 4)", "1 + 2..3 * 4"},
+		{"(1..2)..3", "1..2..3"},
+		{"1..(2..3)", "1..(2..3)"},
+		{"(a + b)!", "(a + b)!"},
+		{"!a.b!", "!a.b!"},
+		{"a?.b", "a?.b"},
+		{"(a + b)?.[0]", "(a + b)?.[0]"},
+		{"unless (x) { y }", "unless (x) { y }"},
+		{"do { x } while (y)", "do { x } while (y)"},
+		{"try { a } catch (e) { b }", "try { a } catch (e) { b }"},
+		{"[x for x in xs if (x > 1)]", "[x for x in xs if x > 1]"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+
+		if source := ast.SourceString(program); source != tt.expected {
+			t.Errorf("SourceString(%q) wrong. expected=%q, got=%q", tt.input, tt.expected, source)
+		}
+	}
+}
+
+func TestSourceStringStatements(t *testing.T) {
+	input := `
+	let add = fn(a, b) { return (a + b) * 2; };
+	let s = "say \"hi\"\n";
+	let [x, ...rest] = [1, 2, 3], y;
+	if (x > 1) { puts({"a": [1, ...rest]}) } else { y = (1, 2) };
+	`
+
+	expected := `let add = fn(a, b) { return (a + b) * 2 };
+let s = "say \"hi\"\n";
+let [x, ...rest] = [1, 2, 3], y;
+if (x > 1) { puts({"a": [1, ...rest]}) } else { y = (1, 2) }`
+
+	program := parseProgram(t, input)
+
+	if source := ast.SourceString(program); source != expected {
+		t.Errorf("SourceString() wrong. expected=\n%s\ngot=\n%s", expected, source)
+	}
+}
+
+func TestSourceStringRoundTrips(t *testing.T) {
+	input := `
+	let result = if (add(1, 2) > 2) { [1, 2, 3][0] } else { -1 };
+	let f = fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };
+	let hash = {"one": 1, "two": add(1, 1)};
+	a, b = b, -(a - b) * c;
+	x |> f(1) |> g;
+	(a ?? b) || !c;
+	return a + b if a > b;
+	xs[1:n - 1:2] + xs[::-1];
+	while ((line = next()) != null) { a = b = line };
+	fn() { outer n = n + 1; global seen = true };
+	fn() { yield n * 2; yield };
+	@memoize @retry(3) let get = fn(url) { fetch(url) };
+	send(b"\x00\x01ok\n\xff");
+	typeof (a + b) == "INTEGER" && (x is Int) == ok;
+	"a" <> ("b" <> c) <> [1, 2] <> xs;
+	unless (x > 1) { y } else { z };
+	do { i = i + 1 } while (i < 10);
+	try { risky() } catch (e) { puts(e) };
+	try { risky() } catch { null };
+	a?.b?.[i + 1].c;
+	[x * 2 for x in xs if x > 1];
+	`
+
+	program := parseProgram(t, input)
+	reparsed := parseProgram(t, ast.SourceString(program))
+
+	if !ast.Equal(program, reparsed) {
+		t.Errorf("reparsed source differs:\n%s\n%s", program.String(), reparsed.String())
+	}
+}
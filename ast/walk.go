@@ -0,0 +1,36 @@
+package ast
+
+import "reflect"
+
+// Walk traverses the AST rooted at node in pre-order. visit is called for
+// every node; when it returns false the children of that node are skipped.
+func Walk(node Node, visit func(Node) bool) {
+	if isNilNode(node) || !visit(node) {
+		return
+	}
+
+	for _, child := range node.Children() {
+		Walk(child, visit)
+	}
+}
+
+// appendNodes appends the nodes that are not nil to children.
+func appendNodes(children []Node, nodes ...Node) []Node {
+	for _, node := range nodes {
+		if !isNilNode(node) {
+			children = append(children, node)
+		}
+	}
+	return children
+}
+
+// isNilNode reports whether node is nil or a typed nil pointer, which the
+// parser leaves behind for optional children such as a missing else branch.
+func isNilNode(node Node) bool {
+	if node == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(node)
+	return value.Kind() == reflect.Ptr && value.IsNil()
+}
@@ -0,0 +1,150 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has errors: %v", p.Errors())
+	}
+
+	return program
+}
+
+func TestWalkCountsNodes(t *testing.T) {
+	input := `
+	let add = fn(a, b) { return a + b; };
+	let result = if (add(1, 2) > 2) { [1, 2, 3][0] } else { -1 };
+	let hash = {"one": 1, "two": add(1, 1)};
+	`
+
+	program := parseProgram(t, input)
+
+	counts := map[string]int{}
+	ast.Walk(program, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.Identifier:
+			counts["identifier"]++
+		case *ast.IntegerLiteral:
+			counts["integer"]++
+		case *ast.StringLiteral:
+			counts["string"]++
+		case *ast.CallExpression:
+			counts["call"]++
+		case *ast.BlockStatement:
+			counts["block"]++
+		case *ast.HashLiteral:
+			counts["hash"]++
+		}
+		return true
+	})
+
+	expected := map[string]int{
+		// add, a, b, a, b, result, add, hash, add
+		"identifier": 9,
+		// 1, 2, 2, 1, 2, 3, 0, 1, 1, 1, 1
+		"integer": 11,
+		"string":  2,
+		"call":    2,
+		"block":   3,
+		"hash":    1,
+	}
+
+	for name, count := range expected {
+		if counts[name] != count {
+			t.Errorf("wrong number of %s nodes. got=%d, expected=%d", name, counts[name], count)
+		}
+	}
+}
+
+func TestWalkPrunesChildren(t *testing.T) {
+	input := `let f = fn(x) { x + 1 }; f(2);`
+
+	program := parseProgram(t, input)
+
+	var visited []string
+	ast.Walk(program, func(node ast.Node) bool {
+		if _, ok := node.(*ast.FunctionLiteral); ok {
+			visited = append(visited, "fn")
+			return false
+		}
+
+		if ident, ok := node.(*ast.Identifier); ok {
+			visited = append(visited, ident.Value)
+		}
+		return true
+	})
+
+	expected := []string{"f", "fn", "f"}
+	if len(visited) != len(expected) {
+		t.Fatalf("wrong visited nodes. got=%v, expected=%v", visited, expected)
+	}
+
+	for i, name := range expected {
+		if visited[i] != name {
+			t.Errorf("visited[%d] wrong. got=%q, expected=%q", i, visited[i], name)
+		}
+	}
+}
+
+func TestWalkStopsAtRoot(t *testing.T) {
+	program := parseProgram(t, `1 + 2; 3;`)
+
+	calls := 0
+	ast.Walk(program, func(node ast.Node) bool {
+		calls++
+		return false
+	})
+
+	if calls != 1 {
+		t.Errorf("visit called %d times, expected=1", calls)
+	}
+}
+
+func TestChildren(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1 + x", []string{"1", "x"}},
+		{"f(a, b)", []string{"f", "a", "b"}},
+		{`{"a": 1, "b": x}`, []string{"a", "1", "b", "x"}},
+		{"if (x) { 1 }", []string{"x", "1"}},
+		{"if (x) { 1 } else { 2 }", []string{"x", "1", "2"}},
+		{"xs[1:]", []string{"xs", "1"}},
+		{"fn f(a) { a }", []string{"f", "a", "a"}},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		node := program.Statements[0].(*ast.ExpressionStatement).Expression
+
+		children := node.Children()
+		if len(children) != len(tt.expected) {
+			t.Errorf("%q: wrong number of children. expected=%d, got=%d", tt.input, len(tt.expected), len(children))
+			continue
+		}
+		for i, child := range children {
+			if child.String() != tt.expected[i] {
+				t.Errorf("%q: children[%d] wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], child.String())
+			}
+		}
+	}
+}
+
+func TestChildrenOfLeaves(t *testing.T) {
+	program := parseProgram(t, `x; 1; 1.5; true; "s"; /re/;`)
+
+	for _, statement := range program.Statements {
+		leaf := statement.(*ast.ExpressionStatement).Expression
+		if children := leaf.Children(); len(children) != 0 {
+			t.Errorf("%T has children: %v", leaf, children)
+		}
+	}
+}
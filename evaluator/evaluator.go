@@ -55,7 +55,13 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 
+	case *ast.BlockExpression:
+		return evalBlockStatement(node.Block, env)
+
 	case *ast.ReturnStatement:
+		if node.ReturnValue == nil {
+			return &object.ReturnValue{Value: NULL}
+		}
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
@@ -63,11 +69,19 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.ReturnValue{Value: val}
 
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		if node.Pattern != nil {
+			return newError("destructuring let is not supported: %s", node.Pattern.String())
+		}
+
+		if val := evalLetBinding(node.Name, node.Value, env); isReturnOrError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+
+		for _, binding := range node.Additional {
+			if val := evalLetBinding(binding.Name, binding.Value, env); isReturnOrError(val) {
+				return val
+			}
+		}
 
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
@@ -75,7 +89,15 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		if node.Name == nil {
+			return &object.Function{Parameters: params, Env: env, Body: body}
+		}
+
+		// a named literal sees itself in its own scope
+		scope := object.NewEnclosedEnvironment(env)
+		function := &object.Function{Parameters: params, Env: scope, Body: body}
+		scope.Set(node.Name.Value, function)
+		return function
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -274,6 +296,24 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+func isReturnOrError(obj object.Object) bool {
+	return isError(obj) || obj != nil && obj.Type() == object.RETURN_VALUE_OBJ
+}
+
+func evalLetBinding(name *ast.Identifier, value ast.Expression, env *object.Environment) object.Object {
+	if value == nil {
+		return env.Set(name.Value, NULL)
+	}
+
+	// a block expression may return from the enclosing function
+	val := Eval(value, env)
+	if isReturnOrError(val) {
+		return val
+	}
+
+	return env.Set(name.Value, val)
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
@@ -382,8 +422,8 @@ func evalHashLiteral(
 ) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	for _, pairNode := range node.Pairs {
+		key := Eval(pairNode.Key, env)
 		if isError(key) {
 			return newError("key error: %s", key.Type())
 			// return key
@@ -394,7 +434,7 @@ func evalHashLiteral(
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(pairNode.Value, env)
 		if isError(value) {
 			return newError("value error: %s", value.Type())
 			// return value
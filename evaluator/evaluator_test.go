@@ -1,6 +1,7 @@
 package evaluator
 
 import (
+	"monkey/ast"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
@@ -194,6 +195,13 @@ f(10);`,
 f(10);`,
 			20,
 		},
+		{`let f = fn() {
+  let v = { return 7; 8 };
+  v + 100;
+};
+f();`,
+			7,
+		},
 	}
 
 	for _, tt := range tests {
@@ -202,6 +210,11 @@ f(10);`,
 	}
 }
 
+func TestBareReturnStatement(t *testing.T) {
+	testNullObject(t, testEval("let f = fn() { return; 10 }; f();"))
+	testNullObject(t, testEval("let f = fn() { { return } }; f();"))
+}
+
 func TestErrorHandling(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -288,6 +301,7 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5 * 5; a;", 25},
 		{"let a = 5; let b = a; b;", 5},
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let a = 5, b = a * 2; b;", 10},
 	}
 
 	for _, tt := range tests {
@@ -295,6 +309,10 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestLetStatementWithoutInitializer(t *testing.T) {
+	testNullObject(t, testEval("let a; a;"))
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
@@ -330,6 +348,9 @@ func TestFunctionApplication(t *testing.T) {
 		{"let add = fn(x, y) { x + y; }; add(5, 5)", 10},
 		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5))", 20},
 		{"fn(x) { x; }(5)", 5},
+		{"fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }(5)", 120},
+		{"fn fact(n) { if (n < 2) return 1; n * fact(n - 1) }(4)", 24},
+		{"let f = fn count(n) { if (n > 0) { count(n - 1) } else { 0 } }; let count = 7; f(3)", 0},
 	}
 
 	for _, tt := range tests {
@@ -458,6 +479,24 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+// TestBuiltinNamesMatchAST checks that ast.Builtins, which FreeVariables and
+// the parser use, lists exactly the evaluator's builtins.
+func TestBuiltinNamesMatchAST(t *testing.T) {
+	names := map[string]bool{}
+	for _, name := range ast.Builtins {
+		if _, ok := builtins[name]; !ok {
+			t.Errorf("ast.Builtins has %q, which is not a builtin", name)
+		}
+		names[name] = true
+	}
+
+	for name := range builtins {
+		if !names[name] {
+			t.Errorf("builtin %q is missing from ast.Builtins", name)
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
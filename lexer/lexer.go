@@ -1,43 +1,260 @@
 package lexer
 
-import "monkey/token"
+import (
+	"fmt"
+	"io"
+	"monkey/token"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
 
 type Lexer struct {
-	input        string
+	src          *source
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // line of the current char
+
+	tokenLine   int // line of the token being read
+	tokenColumn int // column of the token being read
+
+	errors            []string // invalid string literals read so far
+	readErrorReported bool     // the error that ended the input is in errors
+
+	heredocEnd    int // end of the line holding a heredoc start, 0 if none
+	heredocResume int // end of the heredoc's terminator line
+
+	emitNewlines bool            // emit token.NEWLINE to terminate statements
+	emitComments bool            // emit token.COMMENT instead of skipping comments
+	foldKeywords bool            // match keywords regardless of case
+	nesting      int             // depth of open parens and brackets
+	lastType     token.TokenType // type of the last emitted token
+
+	trackIndent bool          // emit token.INDENT and token.DEDENT
+	atLineStart bool          // a line break was read since the last token
+	lineStart   int           // position of the first char of the current line
+	indents     []string      // leading whitespace of the open indentation levels
+	pending     []token.Token // tokens queued by a multi-level dedent
+
+	lookahead []peeked // tokens read ahead by PeekN
+
+	keywords map[string]token.TokenType // replaces the default keywords when set
+}
+
+type Option func(*Lexer)
+
+// WithNewlines makes the lexer emit a token.NEWLINE for a line break that
+// follows a token which can end a statement. Line breaks inside parens and
+// brackets, or before a line starting with `.`, are ignored so expressions
+// can span multiple lines.
+func WithNewlines() Option {
+	return func(l *Lexer) {
+		l.emitNewlines = true
+	}
+}
+
+// WithIndentation makes the lexer emit a token.INDENT when a line is indented
+// deeper than the previous one and a token.DEDENT for every level it closes.
+// Indentation that is inconsistent in its use of tabs and spaces yields a
+// token.ILLEGAL.
+func WithIndentation() Option {
+	return func(l *Lexer) {
+		l.trackIndent = true
+	}
+}
+
+// WithComments makes the lexer emit a token.COMMENT for every `// ...`
+// comment instead of skipping it. The literal is the whole comment including
+// the slashes.
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.emitComments = true
+	}
 }
 
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
+// WithCaseInsensitiveKeywords makes the lexer recognize keywords regardless
+// of their case, so `IF` and `If` lex as token.IF. The literal keeps the
+// original spelling and other identifiers stay case-sensitive.
+func WithCaseInsensitiveKeywords() Option {
+	return func(l *Lexer) {
+		l.foldKeywords = true
+	}
+}
+
+// WithKeywords makes the lexer use keywords instead of the default keyword
+// table, e.g. to lex `wenn` as token.IF. Words missing from keywords, even
+// the default English ones, lex as token.IDENT.
+func WithKeywords(keywords map[string]token.TokenType) Option {
+	return func(l *Lexer) {
+		l.keywords = keywords
+	}
+}
+
+func New(input string, options ...Option) *Lexer {
+	return newLexer(newStringSource(input), options)
+}
+
+// NewReader returns a lexer that reads its input from r as it goes, so
+// tokens are available before the whole input has been read. Input is
+// dropped once the lexer is past its line, so memory use is bounded by the
+// longest line and heredoc rather than the input size. Invalid UTF-8 is read
+// as utf8.RuneError. A read error ends the input and is reported by Errors.
+func NewReader(r io.RuneReader, options ...Option) *Lexer {
+	return newLexer(newReaderSource(r), options)
+}
+
+func newLexer(src *source, options []Option) *Lexer {
+	l := &Lexer{src: src, line: 1, atLineStart: true}
+	for _, option := range options {
+		option(l)
+	}
 	l.readChar()
 	return l
 }
 
+// Tokenize returns all tokens of input, including the final EOF token.
+func Tokenize(input string) []token.Token {
+	return New(input).Tokens()
+}
+
+// Tokens returns the remaining tokens, including the final EOF token. It
+// works on a copy of the lexer, so subsequent NextToken calls are not
+// affected.
+func (l *Lexer) Tokens() []token.Token {
+	// the copy must not drop input the lexer has yet to read
+	l.src.pins++
+	defer func() { l.src.pins-- }()
+
+	lexer := *l
+	lexer.indents = append([]string(nil), l.indents...)
+	lexer.pending = append([]token.Token(nil), l.pending...)
+	lexer.lookahead = append([]peeked(nil), l.lookahead...)
+	tokens := []token.Token{}
+
+	for {
+		tok := lexer.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+	if l.ch == '\n' {
+		l.line++
+		l.atLineStart = true
+		l.lineStart = l.readPosition
 	}
 
+	// skip the body of a heredoc once the line it starts on is done
+	if l.heredocEnd > 0 && l.readPosition == l.heredocEnd {
+		l.skipHeredoc()
+	}
+
+	l.ch = l.src.charAt(l.readPosition)
+
 	l.position = l.readPosition
 	l.readPosition += 1
 }
 
+// peeked is a token read ahead by PeekN with the errors found reading it,
+// which are held back until the token is consumed.
+type peeked struct {
+	token.Token
+	errors []string
+}
+
 func (l *Lexer) NextToken() token.Token {
+	if len(l.lookahead) > 0 {
+		next := l.lookahead[0]
+		l.lookahead = l.lookahead[1:]
+		l.errors = append(l.errors, next.errors...)
+		return next.Token
+	}
+
+	return l.readToken()
+}
+
+// Peek returns the token the next NextToken call will return without
+// consuming it.
+func (l *Lexer) Peek() token.Token {
+	return l.PeekN(1)
+}
+
+// PeekN returns the nth upcoming token without consuming it, where PeekN(1)
+// is the token the next NextToken call will return. Past the end of the input
+// it returns EOF tokens.
+func (l *Lexer) PeekN(n int) token.Token {
+	if n < 1 {
+		return token.Token{}
+	}
+
+	for len(l.lookahead) < n {
+		errorCount := len(l.errors)
+		tok := l.readToken()
+		found := append([]string(nil), l.errors[errorCount:]...)
+		l.errors = l.errors[:errorCount]
+		l.lookahead = append(l.lookahead, peeked{Token: tok, errors: found})
+	}
+
+	return l.lookahead[n-1].Token
+}
+
+func (l *Lexer) readToken() token.Token {
+	keep := l.position
+	if l.trackIndent {
+		keep = l.lineStart
+	}
+	l.src.discard(keep)
+
+	tok := l.nextToken()
+	tok.Line, tok.Column = l.tokenLine, l.tokenColumn
+
+	switch tok.Type {
+	case token.LPAREN, token.LBRACKET:
+		l.nesting++
+	case token.RPAREN, token.RBRACKET:
+		if l.nesting > 0 {
+			l.nesting--
+		}
+	}
+	if tok.Type != token.COMMENT {
+		l.lastType = tok.Type
+	}
+
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
 
+	if len(l.pending) > 0 {
+		tok, l.pending = l.pending[0], l.pending[1:]
+		return tok
+	}
+
 	l.skipWhitespace()
+	l.tokenLine, l.tokenColumn = l.line, l.position-l.lineStart+1
+
+	if l.trackIndent {
+		if tok, ok := l.indentToken(); ok {
+			return tok
+		}
+	}
 
 	switch l.ch {
+	case '\n':
+		tok = token.Token{Type: token.NEWLINE, Literal: "\n"}
 	case '=':
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			tok = l.newTwoCharToken(token.ARROW)
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -56,11 +273,31 @@ func (l *Lexer) NextToken() token.Token {
 		}
 
 	case '/':
+		if l.peekChar() == '/' {
+			return token.Token{Type: token.COMMENT, Literal: l.readComment()}
+		}
+
+		if !l.lastEndsExpression() {
+			saved := *l
+			if literal, ok := l.readRegex(); ok {
+				return token.Token{Type: token.REGEX, Literal: literal}
+			}
+			*l = saved
+		}
+
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '<' && !l.lastEndsExpression() && l.startsHeredoc() {
+			return token.Token{Type: token.STRING, Literal: l.readHeredoc()}
+		}
+
+		if l.peekChar() == '>' {
+			tok = l.newTwoCharToken(token.CONCAT)
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
 		tok = newToken(token.GT, l.ch)
 	case ';':
@@ -79,23 +316,87 @@ func (l *Lexer) NextToken() token.Token {
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
+		if l.src.err != nil && !l.readErrorReported {
+			l.errors = append(l.errors, fmt.Sprintf("read error: %s", l.src.err))
+			l.readErrorReported = true
+		}
 	case '"':
+		raw := l.readString()
+		value, err := unescape(raw)
+		if err != nil {
+			l.errors = append(l.errors, err.Error())
+		}
+
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = value
+	case '`':
+		literal, ok := l.readRawString()
+		if ok {
+			tok.Type = token.STRING
+		} else {
+			tok.Type = token.ILLEGAL
+		}
+		tok.Literal = literal
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '@':
+		tok = newToken(token.AT, l.ch)
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.newTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '?':
+		if l.peekChar() == '.' {
+			tok = l.newTwoCharToken(token.QUESTIONDOT)
+		} else if l.peekChar() == '?' {
+			tok = l.newTwoCharToken(token.NULLCOALESCE)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '>' {
+			tok = l.newTwoCharToken(token.PIPE)
+		} else if l.peekChar() == '|' {
+			tok = l.newTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.BAR, l.ch)
+		}
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else if l.peekChar() == '.' && l.peekCharAt(2) == '<' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.DOTDOTLT, Literal: "..<"}
+		} else if l.peekChar() == '.' {
+			tok = l.newTwoCharToken(token.DOTDOT)
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	default:
-		if isLetter(l.ch) {
+		if l.ch == 'b' && l.peekChar() == '"' {
+			l.readChar()
+			value, err := unescapeBytes(l.readString())
+			if err != nil {
+				l.errors = append(l.errors, err.Error())
+			}
+
+			tok.Type = token.BYTES
+			tok.Literal = value
+		} else if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
-			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Type = l.lookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -116,7 +417,7 @@ func (l *Lexer) readIdentifier() string {
 		l.readChar()
 	}
 
-	return l.input[position:l.position]
+	return l.src.slice(position, l.position)
 }
 
 func isLetter(ch byte) bool {
@@ -124,18 +425,168 @@ func isLetter(ch byte) bool {
 }
 
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '\n':
+			if l.newlineEndsStatement() {
+				return
+			}
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/' && !l.emitComments:
+			l.readComment()
+		default:
+			return
+		}
+	}
+}
+
+// readComment reads a `//` comment up to, but not including, the line break.
+func (l *Lexer) readComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+
+	return l.src.slice(position, l.position)
+}
+
+func (l *Lexer) newlineEndsStatement() bool {
+	if !l.emitNewlines || l.nesting > 0 || l.continuesOnNextLine() {
+		return false
+	}
+
+	switch l.lastType {
+	case token.IDENT, token.INT, token.FLOAT, token.STRING, token.BYTES, token.REGEX, token.TRUE, token.FALSE,
+		token.RETURN, token.BREAK, token.CONTINUE, token.RPAREN, token.RBRACKET, token.RBRACE,
+		token.BANG:
+		return true
+	default:
+		return false
+	}
+}
+
+// continuesOnNextLine reports whether the next non-blank line starts with a
+// `.` access, like in a fluent chain of method calls.
+func (l *Lexer) continuesOnNextLine() bool {
+	offset := 1
+	for {
+		switch l.peekCharAt(offset) {
+		case ' ', '\t', '\r', '\n':
+			offset++
+		case '.':
+			return l.peekCharAt(offset+1) != '.'
+		default:
+			return false
+		}
+	}
+}
+
+// lastEndsExpression reports whether the last token can end an expression,
+// in which case a following '/' is a division and not the start of a regex.
+func (l *Lexer) lastEndsExpression() bool {
+	switch l.lastType {
+	case token.IDENT, token.INT, token.FLOAT, token.STRING, token.BYTES, token.REGEX, token.TRUE, token.FALSE,
+		token.RPAREN, token.RBRACKET, token.RBRACE, token.BANG:
+		return true
+	default:
+		return false
+	}
+}
+
+// indentToken compares the leading whitespace of a new line with the open
+// indentation levels. At the end of input all open levels are closed.
+func (l *Lexer) indentToken() (token.Token, bool) {
+	if l.ch == 0 {
+		if len(l.indents) == 0 {
+			return token.Token{}, false
+		}
+		l.indents = l.indents[:len(l.indents)-1]
+		return token.Token{Type: token.DEDENT, Literal: ""}, true
+	}
+
+	if !l.atLineStart {
+		return token.Token{}, false
+	}
+	l.atLineStart = false
+
+	if l.nesting > 0 {
+		return token.Token{}, false
+	}
+
+	indent := l.src.slice(l.lineStart, l.position)
+	current := ""
+	if len(l.indents) > 0 {
+		current = l.indents[len(l.indents)-1]
+	}
+
+	switch {
+	case indent == current:
+		return token.Token{}, false
+
+	case strings.HasPrefix(indent, current):
+		l.indents = append(l.indents, indent)
+		return token.Token{Type: token.INDENT, Literal: indent}, true
+
+	case strings.HasPrefix(current, indent):
+		tokens := []token.Token{}
+		for current != indent && strings.HasPrefix(current, indent) {
+			l.indents = l.indents[:len(l.indents)-1]
+			tokens = append(tokens, token.Token{Type: token.DEDENT, Literal: ""})
+
+			current = ""
+			if len(l.indents) > 0 {
+				current = l.indents[len(l.indents)-1]
+			}
+		}
+
+		if current != indent {
+			tokens = append(tokens, token.Token{Type: token.ILLEGAL, Literal: indent})
+		}
+
+		l.pending = append(l.pending, tokens[1:]...)
+		return tokens[0], true
+
+	default:
+		return token.Token{Type: token.ILLEGAL, Literal: indent}, true
+	}
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or a float with an optional fraction,
+// exponent and type suffix like the i in `5i`. An exponent without digits and
+// unknown suffixes are kept in the literal, so the parser can report them.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
-	for isDigit(l.ch) {
+	var tokenType token.TokenType = token.INT
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
 		l.readChar()
+		l.readDigits()
 	}
 
-	return l.input[position:l.position]
+	if l.ch == 'e' || l.ch == 'E' {
+		tokenType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		l.readDigits()
+	}
+
+	for isLetter(l.ch) {
+		l.readChar()
+	}
+
+	return l.src.slice(position, l.position), tokenType
+}
+
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) {
+		l.readChar()
+	}
 }
 
 func isDigit(ch byte) bool {
@@ -143,11 +594,11 @@ func isDigit(ch byte) bool {
 }
 
 func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
-		return 0
-	} else {
-		return l.input[l.readPosition]
-	}
+	return l.src.charAt(l.readPosition)
+}
+
+func (l *Lexer) peekCharAt(offset int) byte {
+	return l.src.charAt(l.position + offset)
 }
 
 func (l *Lexer) newTwoCharToken(tokenType token.TokenType) token.Token {
@@ -160,11 +611,328 @@ func (l *Lexer) readString() string {
 	position := l.position + 1
 	for {
 		l.readChar()
+		if l.ch == '\\' && l.peekChar() != 0 {
+			l.readChar()
+			continue
+		}
 		if l.ch == '"' || l.ch == 0 {
 			break
 		}
 	}
 
 	// TODO throw error when no closing " found
-	return l.input[position:l.position]
+	return l.src.slice(position, l.position)
+}
+
+// Errors returns the errors about invalid string literals found in the
+// tokens read so far. Errors in tokens only peeked at are left out until the
+// tokens are read.
+func (l *Lexer) Errors() []string {
+	return l.errors
+}
+
+// unescape decodes the escape sequences of a double-quoted string and checks
+// that the result is valid UTF-8.
+func unescape(raw string) (string, error) {
+	if !strings.ContainsRune(raw, '\\') {
+		if !utf8.ValidString(raw) {
+			return raw, fmt.Errorf("string literal %q is not valid UTF-8", raw)
+		}
+		return raw, nil
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' || i+1 == len(raw) {
+			out.WriteByte(raw[i])
+			continue
+		}
+
+		i++
+		switch raw[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case '"', '\\':
+			out.WriteByte(raw[i])
+		case 'x':
+			value, err := readHexEscape(raw, i, 2)
+			if err != nil {
+				return raw, err
+			}
+			out.WriteByte(byte(value))
+			i += 2
+		case 'u', 'U':
+			digits := 4
+			if raw[i] == 'U' {
+				digits = 8
+			}
+
+			value, err := readHexEscape(raw, i, digits)
+			if err != nil {
+				return raw, err
+			}
+			if !utf8.ValidRune(rune(value)) {
+				return raw, fmt.Errorf("invalid code point %s in string literal", raw[i-1:i+1+digits])
+			}
+			out.WriteRune(rune(value))
+			i += digits
+		default:
+			return raw, fmt.Errorf("invalid escape sequence \\%c in string literal", raw[i])
+		}
+	}
+
+	value := out.String()
+	if !utf8.ValidString(value) {
+		return value, fmt.Errorf("string literal %q is not valid UTF-8", raw)
+	}
+
+	return value, nil
+}
+
+// unescapeBytes decodes the escape sequences of a `b"..."` literal. Only
+// ASCII and escapes producing a single byte are allowed.
+func unescapeBytes(raw string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] > 0x7f {
+			return raw, fmt.Errorf("bytes literal %q contains a non-ASCII character, use \\x escapes", raw)
+		}
+		if raw[i] != '\\' || i+1 == len(raw) {
+			out.WriteByte(raw[i])
+			continue
+		}
+
+		i++
+		switch raw[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case '"', '\\':
+			out.WriteByte(raw[i])
+		case 'x':
+			value, err := readHexEscape(raw, i, 2)
+			if err != nil {
+				return raw, err
+			}
+			out.WriteByte(byte(value))
+			i += 2
+		default:
+			return raw, fmt.Errorf("invalid escape sequence \\%c in bytes literal", raw[i])
+		}
+	}
+
+	return out.String(), nil
+}
+
+// readHexEscape parses the digits hex digits following the escape letter at
+// raw[i].
+func readHexEscape(raw string, i int, digits int) (uint64, error) {
+	end := i + 1
+	for end < len(raw) && end < i+1+digits && isHexDigit(raw[end]) {
+		end++
+	}
+
+	if end-(i+1) != digits {
+		return 0, fmt.Errorf("\\%c escape needs %d hex digits, got \\%s", raw[i], digits, raw[i:end])
+	}
+
+	return strconv.ParseUint(raw[i+1:end], 16, 32)
+}
+
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+// readRegex reads a regex literal like `/ab+c/i` including its delimiters and
+// flags. A '/' inside a character class or escaped by a backslash does not
+// end the pattern. It reports false if the pattern is not closed on the same
+// line, so the '/' can be lexed as a division instead.
+func (l *Lexer) readRegex() (string, bool) {
+	position := l.position
+	inClass := false
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case 0, '\n':
+			return l.src.slice(position, l.position), false
+		case '\\':
+			if l.peekChar() != 0 && l.peekChar() != '\n' {
+				l.readChar()
+			}
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if !inClass {
+				l.readChar()
+				for isLetter(l.ch) {
+					l.readChar()
+				}
+				return l.src.slice(position, l.position), true
+			}
+		}
+	}
+}
+
+func (l *Lexer) lookupIdent(ident string) token.TokenType {
+	if l.foldKeywords {
+		ident = strings.ToLower(ident)
+	}
+
+	if l.keywords == nil {
+		return token.LookupIdent(ident)
+	}
+	if tok, ok := l.keywords[ident]; ok {
+		return tok
+	}
+	return token.IDENT
+}
+
+// startsHeredoc reports whether the current "<<" is followed by a heredoc
+// terminator like END or ~END.
+func (l *Lexer) startsHeredoc() bool {
+	i := l.readPosition + 1
+	if l.src.charAt(i) == '~' {
+		i++
+	}
+	return isLetter(l.src.charAt(i))
+}
+
+// readHeredoc reads a heredoc like
+//
+//	<<END
+//	text
+//	END
+//
+// The body starts on the line after <<END and ends before the line equal to
+// the terminator, each body line ends with a newline. With <<~END the
+// terminator may be indented and the common indentation of the body is
+// removed. The rest of the line after <<END is tokenized as usual before the
+// body is skipped.
+func (l *Lexer) readHeredoc() string {
+	l.readChar()
+	l.readChar()
+
+	dedent := l.ch == '~'
+	if dedent {
+		l.readChar()
+	}
+	terminator := l.readIdentifier()
+
+	lineEnd := l.src.indexByte(l.position, '\n')
+	if lineEnd < 0 {
+		l.errors = append(l.errors, fmt.Sprintf("unterminated heredoc, missing terminator %s", terminator))
+		return ""
+	}
+
+	lines := []string{}
+	resume := -1
+	for start := lineEnd + 1; ; {
+		end := l.src.indexByte(start, '\n')
+		last := end < 0
+		if last {
+			end = l.src.len()
+		}
+
+		line := strings.TrimSuffix(l.src.slice(start, end), "\r")
+		if line == terminator || dedent && strings.TrimSpace(line) == terminator {
+			resume = end
+			break
+		}
+
+		lines = append(lines, line)
+		if last {
+			break
+		}
+		start = end + 1
+	}
+
+	if resume < 0 {
+		l.errors = append(l.errors, fmt.Sprintf("unterminated heredoc, missing terminator %s", terminator))
+		resume = l.src.len()
+	}
+
+	l.heredocEnd, l.heredocResume = lineEnd, resume
+	if l.position == lineEnd {
+		// the terminator ends the line, so the newline was already read
+		l.skipHeredoc()
+		l.position = l.readPosition
+		l.readPosition++
+		l.ch = l.src.charAt(l.position)
+	}
+
+	if dedent {
+		lines = dedentLines(lines)
+	}
+
+	var out strings.Builder
+	for _, line := range lines {
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+
+	return out.String()
+}
+
+// skipHeredoc moves the read position past the pending heredoc body.
+func (l *Lexer) skipHeredoc() {
+	l.line += strings.Count(l.src.slice(l.heredocEnd, l.heredocResume), "\n")
+	l.readPosition = l.heredocResume
+	l.heredocEnd = 0
+}
+
+// dedentLines removes the leading whitespace common to all non-blank lines.
+func dedentLines(lines []string) []string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		width := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || width < indent {
+			indent = width
+		}
+	}
+
+	if indent <= 0 {
+		return lines
+	}
+
+	dedented := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= indent {
+			dedented[i] = line[indent:]
+		} else {
+			dedented[i] = ""
+		}
+	}
+
+	return dedented
+}
+
+// readRawString reads a backtick-delimited string verbatim, including
+// newlines and backslashes. It reports false if the closing backtick is
+// missing.
+func (l *Lexer) readRawString() (string, bool) {
+	position := l.position + 1
+	for {
+		l.readChar()
+		if l.ch == '`' {
+			return l.src.slice(position, l.position), true
+		}
+		if l.ch == 0 {
+			return l.src.slice(position, l.position), false
+		}
+	}
 }
@@ -1,12 +1,16 @@
 package lexer
 
 import (
+	"bufio"
+	"errors"
+	"io"
 	"monkey/token"
+	"strings"
 	"testing"
 )
 
 func TestNextTokenOneCharacter(t *testing.T) {
-	input := `=+(){},;-/*<>`
+	input := `=+(){},;-/*< >@.`
 
 	tests := []struct {
 		expectedType token.TokenType
@@ -24,6 +28,8 @@ func TestNextTokenOneCharacter(t *testing.T) {
 		{token.ASTERISK},
 		{token.LT},
 		{token.GT},
+		{token.AT},
+		{token.DOT},
 		{token.EOF},
 	}
 
@@ -43,13 +49,24 @@ func TestNextTokenOneCharacter(t *testing.T) {
 }
 
 func TestNextTokenTwoCharacters(t *testing.T) {
-	input := `== !=`
+	input := `== != |> .. ..< => ?. && || ?? | <>`
 
 	tests := []struct {
 		expectedType token.TokenType
 	}{
 		{token.EQ},
 		{token.NOT_EQ},
+		{token.PIPE},
+		{token.DOTDOT},
+		{token.DOTDOTLT},
+		{token.ARROW},
+		{token.QUESTIONDOT},
+		{token.AND},
+		{token.OR},
+		{token.NULLCOALESCE},
+		{token.BAR},
+		{token.CONCAT},
+		{token.EOF},
 	}
 
 	lexer := New(input)
@@ -63,8 +80,39 @@ func TestNextTokenTwoCharacters(t *testing.T) {
 	}
 }
 
+func TestNextTokenEllipsis(t *testing.T) {
+	input := `[a, ...b]`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LBRACKET, "["},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "b"},
+		{token.RBRACKET, "]"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+
+	for i, tt := range tests {
+		nextToken := lexer.NextToken()
+
+		if nextToken.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, nextToken.Type)
+		}
+
+		if nextToken.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, nextToken.Literal)
+		}
+	}
+}
+
 func TestNextTokenKeywords(t *testing.T) {
-	input := `fn let true false if else return`
+	input := `fn let true false if else return unless while do break macro try catch const continue for in import from export and or not struct match print interface then yield typeof is`
 
 	tests := []struct {
 		expectedType token.TokenType
@@ -76,6 +124,31 @@ func TestNextTokenKeywords(t *testing.T) {
 		{token.IF},
 		{token.ELSE},
 		{token.RETURN},
+		{token.UNLESS},
+		{token.WHILE},
+		{token.DO},
+		{token.BREAK},
+		{token.MACRO},
+		{token.TRY},
+		{token.CATCH},
+		{token.CONST},
+		{token.CONTINUE},
+		{token.FOR},
+		{token.IN},
+		{token.IMPORT},
+		{token.FROM},
+		{token.EXPORT},
+		{token.AND_KW},
+		{token.OR_KW},
+		{token.NOT_KW},
+		{token.STRUCT},
+		{token.MATCH},
+		{token.PRINT},
+		{token.INTERFACE},
+		{token.THEN},
+		{token.YIELD},
+		{token.TYPEOF},
+		{token.IS},
 		{token.EOF},
 	}
 
@@ -233,3 +306,953 @@ func TestNextTokenMultipleLines(t *testing.T) {
 		}
 	}
 }
+
+func TestNextTokenRawString(t *testing.T) {
+	input := "`first line\\n\nC:\\path\\to`;"
+
+	lexer := New(input)
+
+	tok := lexer.NextToken()
+	if tok.Type != token.STRING {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.STRING, tok.Type)
+	}
+
+	expected := "first line\\n\nC:\\path\\to"
+	if tok.Literal != expected {
+		t.Fatalf("literal wrong. expected=%q, got=%q", expected, tok.Literal)
+	}
+
+	if tok := lexer.NextToken(); tok.Type != token.SEMICOLON {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.SEMICOLON, tok.Type)
+	}
+}
+
+func TestNextTokenUnterminatedRawString(t *testing.T) {
+	lexer := New("`never closed")
+
+	tok := lexer.NextToken()
+	if tok.Type != token.ILLEGAL {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.ILLEGAL, tok.Type)
+	}
+
+	if tok := lexer.NextToken(); tok.Type != token.EOF {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.EOF, tok.Type)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	input := `let s = "hi"; add(s, [1, 25]);`
+
+	expected := []token.Token{
+		{Type: token.LET, Literal: "let"},
+		{Type: token.IDENT, Literal: "s"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.STRING, Literal: "hi"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "add"},
+		{Type: token.LPAREN, Literal: "("},
+		{Type: token.IDENT, Literal: "s"},
+		{Type: token.COMMA, Literal: ","},
+		{Type: token.LBRACKET, Literal: "["},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.COMMA, Literal: ","},
+		{Type: token.INT, Literal: "25"},
+		{Type: token.RBRACKET, Literal: "]"},
+		{Type: token.RPAREN, Literal: ")"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	tokens := Tokenize(input)
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d: %v", len(expected), len(tokens), tokens)
+	}
+
+	for i, tok := range tokens {
+		if tok.Type != expected[i].Type || tok.Literal != expected[i].Literal {
+			t.Errorf("tokens[%d] wrong. expected=%+v, got=%+v", i, expected[i], tok)
+		}
+	}
+}
+
+func TestTokensDoesNotConsumeInput(t *testing.T) {
+	lexer := New("a + b")
+	lexer.NextToken()
+
+	tokens := lexer.Tokens()
+	if len(tokens) != 3 {
+		t.Fatalf("wrong number of tokens. expected=3, got=%d: %v", len(tokens), tokens)
+	}
+
+	if tok := lexer.NextToken(); tok.Type != token.PLUS {
+		t.Fatalf("NextToken after Tokens wrong. expected=%q, got=%q", token.PLUS, tok.Type)
+	}
+}
+
+func TestNextTokenNewlines(t *testing.T) {
+	input := `let x = add(1,
+	2)
+
+x
+let y = [
+  1, 2
+]
+`
+
+	tests := []struct {
+		expectedType token.TokenType
+	}{
+		{token.LET},
+		{token.IDENT},
+		{token.ASSIGN},
+		{token.IDENT},
+		{token.LPAREN},
+		{token.INT},
+		{token.COMMA},
+		{token.INT},
+		{token.RPAREN},
+		{token.NEWLINE},
+		{token.IDENT},
+		{token.NEWLINE},
+		{token.LET},
+		{token.IDENT},
+		{token.ASSIGN},
+		{token.LBRACKET},
+		{token.INT},
+		{token.COMMA},
+		{token.INT},
+		{token.RBRACKET},
+		{token.NEWLINE},
+		{token.EOF},
+	}
+
+	lexer := New(input, WithNewlines())
+
+	for i, tt := range tests {
+		nextToken := lexer.NextToken()
+
+		if nextToken.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, nextToken.Type)
+		}
+	}
+}
+
+func TestNextTokenNewlineBeforeDot(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		{"a\n  .b", []token.TokenType{token.IDENT, token.DOT, token.IDENT, token.EOF}},
+		{"a\n\n.b()", []token.TokenType{token.IDENT, token.DOT, token.IDENT, token.LPAREN, token.RPAREN, token.EOF}},
+		{"a\n..b", []token.TokenType{token.IDENT, token.NEWLINE, token.DOTDOT, token.IDENT, token.EOF}},
+		{"a\nb", []token.TokenType{token.IDENT, token.NEWLINE, token.IDENT, token.EOF}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input, WithNewlines())
+
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected {
+				t.Errorf("%q: tokens[%d] wrong. expected=%q, got=%q", tt.input, i, expected, tok.Type)
+				break
+			}
+		}
+	}
+}
+
+func TestNextTokenNewlinesDisabledByDefault(t *testing.T) {
+	for _, tok := range Tokenize("x\ny\n") {
+		if tok.Type == token.NEWLINE {
+			t.Fatalf("unexpected NEWLINE token without WithNewlines")
+		}
+	}
+}
+
+func TestNextTokenCaseInsensitiveKeywords(t *testing.T) {
+	input := "LET x = If (Y) { return TRUE }"
+
+	expected := []token.Token{
+		{Type: token.LET, Literal: "LET"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.IF, Literal: "If"},
+		{Type: token.LPAREN, Literal: "("},
+		{Type: token.IDENT, Literal: "Y"},
+		{Type: token.RPAREN, Literal: ")"},
+		{Type: token.LBRACE, Literal: "{"},
+		{Type: token.RETURN, Literal: "return"},
+		{Type: token.TRUE, Literal: "TRUE"},
+		{Type: token.RBRACE, Literal: "}"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	l := New(input, WithCaseInsensitiveKeywords())
+
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Fatalf("tokens[%d] wrong. expected=%q %q, got=%q %q", i, tt.Type, tt.Literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenCustomKeywords(t *testing.T) {
+	keywords := map[string]token.TokenType{
+		"wenn":  token.IF,
+		"sonst": token.ELSE,
+		"sei":   token.LET,
+	}
+	input := "sei x = wenn (y) { 1 } sonst { if }"
+
+	expected := []token.Token{
+		{Type: token.LET, Literal: "sei"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.IF, Literal: "wenn"},
+		{Type: token.LPAREN, Literal: "("},
+		{Type: token.IDENT, Literal: "y"},
+		{Type: token.RPAREN, Literal: ")"},
+		{Type: token.LBRACE, Literal: "{"},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.RBRACE, Literal: "}"},
+		{Type: token.ELSE, Literal: "sonst"},
+		{Type: token.LBRACE, Literal: "{"},
+		{Type: token.IDENT, Literal: "if"},
+		{Type: token.RBRACE, Literal: "}"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	l := New(input, WithKeywords(keywords))
+
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Fatalf("tokens[%d] wrong. expected=%q %q, got=%q %q", i, tt.Type, tt.Literal, tok.Type, tok.Literal)
+		}
+	}
+
+	if tok := New("wenn if").NextToken(); tok.Type != token.IDENT {
+		t.Errorf("default keywords changed. wenn lexed as %q", tok.Type)
+	}
+}
+
+func TestNextTokenCustomKeywordsCaseInsensitive(t *testing.T) {
+	l := New("WENN", WithKeywords(map[string]token.TokenType{"wenn": token.IF}), WithCaseInsensitiveKeywords())
+
+	if tok := l.NextToken(); tok.Type != token.IF || tok.Literal != "WENN" {
+		t.Errorf("token wrong. got=%q %q", tok.Type, tok.Literal)
+	}
+}
+
+func TestNextTokenCaseSensitiveKeywordsByDefault(t *testing.T) {
+	tokens := Tokenize("IF if")
+
+	if tokens[0].Type != token.IDENT || tokens[0].Literal != "IF" {
+		t.Errorf("IF should be an identifier by default. got=%q %q", tokens[0].Type, tokens[0].Literal)
+	}
+	if tokens[1].Type != token.IF {
+		t.Errorf("if should be token.IF. got=%q", tokens[1].Type)
+	}
+}
+
+func TestNextTokenIndentation(t *testing.T) {
+	input := `if x
+    a
+    if y
+        b
+
+        c
+d
+if z
+  e
+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IF, "if"},
+		{token.IDENT, "x"},
+		{token.INDENT, "    "},
+		{token.IDENT, "a"},
+		{token.IF, "if"},
+		{token.IDENT, "y"},
+		{token.INDENT, "        "},
+		{token.IDENT, "b"},
+		{token.IDENT, "c"},
+		{token.DEDENT, ""},
+		{token.DEDENT, ""},
+		{token.IDENT, "d"},
+		{token.IF, "if"},
+		{token.IDENT, "z"},
+		{token.INDENT, "  "},
+		{token.IDENT, "e"},
+		{token.DEDENT, ""},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input, WithIndentation())
+
+	for i, tt := range tests {
+		nextToken := lexer.NextToken()
+
+		if nextToken.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, nextToken.Type)
+		}
+
+		if nextToken.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, nextToken.Literal)
+		}
+	}
+}
+
+func TestNextTokenIndentationIgnoresContinuationLines(t *testing.T) {
+	input := "f(1,\n    2)\ng"
+
+	expected := []token.TokenType{
+		token.IDENT, token.LPAREN, token.INT, token.COMMA, token.INT, token.RPAREN, token.IDENT, token.EOF,
+	}
+
+	tokens := New(input, WithIndentation()).Tokens()
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d: %v", len(expected), len(tokens), tokens)
+	}
+
+	for i, tok := range tokens {
+		if tok.Type != expected[i] {
+			t.Errorf("tokens[%d] - tokentype wrong. expected=%q, got=%q", i, expected[i], tok.Type)
+		}
+	}
+}
+
+func TestNextTokenIndentationInconsistent(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+	}{
+		{"a\n    b\n\tc", "\t"},
+		{"a\n\tb\n  \tc", "  \t"},
+		{"a\n    b\n  c", "  "},
+	}
+
+	for _, tt := range tests {
+		var illegal *token.Token
+		for _, tok := range New(tt.input, WithIndentation()).Tokens() {
+			if tok.Type == token.ILLEGAL {
+				illegal = &tok
+				break
+			}
+		}
+
+		if illegal == nil {
+			t.Errorf("expected ILLEGAL token for %q", tt.input)
+			continue
+		}
+
+		if illegal.Literal != tt.expectedLiteral {
+			t.Errorf("ILLEGAL literal wrong for %q. expected=%q, got=%q", tt.input, tt.expectedLiteral, illegal.Literal)
+		}
+	}
+}
+
+func TestNextTokenIndentationDisabledByDefault(t *testing.T) {
+	for _, tok := range Tokenize("a\n  b\nc") {
+		if tok.Type == token.INDENT || tok.Type == token.DEDENT {
+			t.Fatalf("unexpected %s token without WithIndentation", tok.Type)
+		}
+	}
+}
+
+func TestNextTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  add(x,\n\ty)"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"add", 2, 3},
+		{"(", 2, 6},
+		{"x", 2, 7},
+		{",", 2, 8},
+		{"y", 3, 2},
+		{")", 3, 3},
+		{"", 3, 4},
+	}
+
+	lexer := New(input)
+
+	for i, tt := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - position of %q wrong. expected=%d:%d, got=%d:%d",
+				i, tok.Literal, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestNextTokenRegex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"x = /ab+c/i", []token.Token{
+			{Type: token.IDENT, Literal: "x"},
+			{Type: token.ASSIGN, Literal: "="},
+			{Type: token.REGEX, Literal: "/ab+c/i"},
+		}},
+		{"a / b", []token.Token{
+			{Type: token.IDENT, Literal: "a"},
+			{Type: token.SLASH, Literal: "/"},
+			{Type: token.IDENT, Literal: "b"},
+		}},
+		{"a / b / c", []token.Token{
+			{Type: token.IDENT, Literal: "a"},
+			{Type: token.SLASH, Literal: "/"},
+			{Type: token.IDENT, Literal: "b"},
+			{Type: token.SLASH, Literal: "/"},
+			{Type: token.IDENT, Literal: "c"},
+		}},
+		{"(4) / 2", []token.Token{
+			{Type: token.LPAREN, Literal: "("},
+			{Type: token.INT, Literal: "4"},
+			{Type: token.RPAREN, Literal: ")"},
+			{Type: token.SLASH, Literal: "/"},
+			{Type: token.INT, Literal: "2"},
+		}},
+		{`find(s, /a\/b[/]/)`, []token.Token{
+			{Type: token.IDENT, Literal: "find"},
+			{Type: token.LPAREN, Literal: "("},
+			{Type: token.IDENT, Literal: "s"},
+			{Type: token.COMMA, Literal: ","},
+			{Type: token.REGEX, Literal: `/a\/b[/]/`},
+			{Type: token.RPAREN, Literal: ")"},
+		}},
+		{"-/*5", []token.Token{
+			{Type: token.MINUS, Literal: "-"},
+			{Type: token.SLASH, Literal: "/"},
+			{Type: token.ASTERISK, Literal: "*"},
+			{Type: token.INT, Literal: "5"},
+		}},
+	}
+
+	for _, tt := range tests {
+		tokens := Tokenize(tt.input)
+		expected := append(tt.expected, token.Token{Type: token.EOF, Literal: ""})
+
+		if len(tokens) != len(expected) {
+			t.Fatalf("wrong number of tokens for %q. expected=%d, got=%d: %v", tt.input, len(expected), len(tokens), tokens)
+		}
+
+		for i, tok := range tokens {
+			if tok.Type != expected[i].Type || tok.Literal != expected[i].Literal {
+				t.Errorf("%q: tokens[%d] wrong. expected=%+v, got=%+v", tt.input, i, expected[i], tok)
+			}
+		}
+	}
+}
+
+func TestNextTokenComments(t *testing.T) {
+	input := `// doc
+let x = 5; // note
+x / 2 //end`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// doc"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// note"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.COMMENT, "//end"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input, WithComments())
+
+	for i, tt := range tests {
+		nextToken := lexer.NextToken()
+
+		if nextToken.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, nextToken.Type)
+		}
+
+		if nextToken.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, nextToken.Literal)
+		}
+	}
+}
+
+func TestNextTokenSkipsCommentsByDefault(t *testing.T) {
+	input := "// doc\nx // note\n/ 2"
+
+	expected := []token.TokenType{token.IDENT, token.SLASH, token.INT, token.EOF}
+
+	tokens := Tokenize(input)
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d: %v", len(expected), len(tokens), tokens)
+	}
+
+	for i, tok := range tokens {
+		if tok.Type != expected[i] {
+			t.Errorf("tokens[%d] - tokentype wrong. expected=%q, got=%q", i, expected[i], tok.Type)
+		}
+	}
+}
+
+func TestNextTokenStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"\x41"`, "A"},
+		{`"a\tb\n"`, "a\tb\n"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"\\"`, `\`},
+		{`"é"`, "é"},
+		{`"\U0001F600"`, "😀"},
+		{`"\xe2\x82\xac"`, "€"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("%s - tokentype wrong. expected=%q, got=%q", tt.input, token.STRING, tok.Type)
+		}
+
+		if tok.Literal != tt.expected {
+			t.Errorf("%s - literal wrong. expected=%q, got=%q", tt.input, tt.expected, tok.Literal)
+		}
+
+		if len(l.Errors()) != 0 {
+			t.Errorf("%s - unexpected errors: %q", tt.input, l.Errors())
+		}
+	}
+}
+
+func TestNextTokenInvalidStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"\x1"`, `\x escape needs 2 hex digits, got \x1`},
+		{`"\xzz"`, `\x escape needs 2 hex digits, got \x`},
+		{`"\U00110000"`, `invalid code point \U00110000 in string literal`},
+		{`"\uD800"`, `invalid code point \uD800 in string literal`},
+		{`"\U0001F6"`, `\U escape needs 8 hex digits, got \U0001F6`},
+		{`"\q"`, `invalid escape sequence \q in string literal`},
+		{`"\xff"`, `string literal "\\xff" is not valid UTF-8`},
+		{"\"\xff\"", `string literal "\xff" is not valid UTF-8`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("%s - tokentype wrong. expected=%q, got=%q", tt.input, token.STRING, tok.Type)
+		}
+
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("%s - expected 1 error, got=%q", tt.input, errors)
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("%s - error wrong. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestNextTokenBytes(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedType  token.TokenType
+		expected      string
+		expectedError string
+	}{
+		{`b"AB"`, token.BYTES, "AB", ""},
+		{`b"\x00\xff\n"`, token.BYTES, "\x00\xff\n", ""},
+		{`b""`, token.BYTES, "", ""},
+		{`b"\u0041"`, token.BYTES, `\u0041`, `invalid escape sequence \u in bytes literal`},
+		{`b"é"`, token.BYTES, "é", `bytes literal "é" contains a non-ASCII character, use \x escapes`},
+		{`b "AB"`, token.IDENT, "b", ""},
+		{`bar"AB"`, token.IDENT, "bar", ""},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expected {
+			t.Errorf("%s - token wrong. expected=%q %q, got=%q %q", tt.input, tt.expectedType, tt.expected, tok.Type, tok.Literal)
+		}
+
+		errors := l.Errors()
+		if tt.expectedError == "" && len(errors) != 0 {
+			t.Errorf("%s - unexpected errors: %q", tt.input, errors)
+		}
+		if tt.expectedError != "" && (len(errors) != 1 || errors[0] != tt.expectedError) {
+			t.Errorf("%s - errors wrong. expected=%q, got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestPeekN(t *testing.T) {
+	input := "let x = (a, b) => a;"
+
+	expected := Tokenize(input)
+	l := New(input)
+
+	for i := 1; i <= len(expected)+1; i++ {
+		want := expected[len(expected)-1]
+		if i <= len(expected) {
+			want = expected[i-1]
+		}
+
+		tok := l.PeekN(i)
+		if tok.Type != want.Type || tok.Literal != want.Literal {
+			t.Fatalf("PeekN(%d) wrong. expected=%q %q, got=%q %q", i, want.Type, want.Literal, tok.Type, tok.Literal)
+		}
+	}
+
+	if tok := l.Peek(); tok.Type != token.LET {
+		t.Fatalf("Peek() advanced the lexer. got=%q", tok.Type)
+	}
+
+	for i, want := range expected {
+		if i == 3 {
+			if tok := l.PeekN(2); tok.Type != token.IDENT || tok.Literal != "a" {
+				t.Fatalf("PeekN(2) wrong after NextToken. got=%q %q", tok.Type, tok.Literal)
+			}
+		}
+
+		tok := l.NextToken()
+		if tok != want {
+			t.Fatalf("tokens[%d] wrong. expected=%+v, got=%+v", i, want, tok)
+		}
+	}
+}
+
+func TestPeekNHoldsBackErrors(t *testing.T) {
+	l := New(`x "\q"`)
+
+	if tok := l.PeekN(2); tok.Type != token.STRING {
+		t.Fatalf("PeekN(2) is not a string. got=%q", tok.Type)
+	}
+	if len(l.Errors()) != 0 {
+		t.Fatalf("errors of a peeked token reported early. got=%q", l.Errors())
+	}
+
+	l.NextToken()
+	l.NextToken()
+	if len(l.Errors()) != 1 {
+		t.Fatalf("errors of a read token not reported. got=%q", l.Errors())
+	}
+}
+
+func TestPeekNKeepsLexerModes(t *testing.T) {
+	input := "x = 1\n(y)\n/ 2 /"
+
+	expected := New(input, WithNewlines()).Tokens()
+	l := New(input, WithNewlines())
+
+	if tok := l.PeekN(len(expected)); tok.Type != token.EOF {
+		t.Fatalf("PeekN(%d) is not EOF. got=%q", len(expected), tok.Type)
+	}
+
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok != want {
+			t.Fatalf("tokens[%d] wrong. expected=%+v, got=%+v", i, want, tok)
+		}
+	}
+}
+
+func TestNextTokenNumbers(t *testing.T) {
+	input := "5 1.5 1e10 2.5e-3 6.022E23 1e+2 1e 1..10 x.y 5i 3.0f 1e5f 10u 5z"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "1.5"},
+		{token.FLOAT, "1e10"},
+		{token.FLOAT, "2.5e-3"},
+		{token.FLOAT, "6.022E23"},
+		{token.FLOAT, "1e+2"},
+		{token.FLOAT, "1e"},
+		{token.INT, "1"},
+		{token.DOTDOT, ".."},
+		{token.INT, "10"},
+		{token.IDENT, "x"},
+		{token.DOT, "."},
+		{token.IDENT, "y"},
+		{token.INT, "5i"},
+		{token.FLOAT, "3.0f"},
+		{token.FLOAT, "1e5f"},
+		{token.INT, "10u"},
+		{token.INT, "5z"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenHeredoc(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{
+			"let s = <<END;\nline one\n  line two\nEND\ns",
+			[]token.Token{
+				{Type: token.LET, Literal: "let"},
+				{Type: token.IDENT, Literal: "s"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.STRING, Literal: "line one\n  line two\n"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.IDENT, Literal: "s"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			"f(<<~TEXT, 1)\n    a\n      b\n\n    c\n  TEXT\n",
+			[]token.Token{
+				{Type: token.IDENT, Literal: "f"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.STRING, Literal: "a\n  b\n\nc\n"},
+				{Type: token.COMMA, Literal: ","},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			"a << b",
+			[]token.Token{
+				{Type: token.IDENT, Literal: "a"},
+				{Type: token.LT, Literal: "<"},
+				{Type: token.LT, Literal: "<"},
+				{Type: token.IDENT, Literal: "b"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("%q: tokens[%d] wrong. expected=%q %q, got=%q %q",
+					tt.input, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+
+		if len(l.Errors()) != 0 {
+			t.Errorf("%q: unexpected errors: %q", tt.input, l.Errors())
+		}
+	}
+}
+
+func TestNextTokenHeredocPositions(t *testing.T) {
+	input := "let s = <<END\na\nb\nEND\nx"
+
+	tokens := Tokenize(input)
+	last := tokens[len(tokens)-2]
+
+	if last.Literal != "x" || last.Line != 5 || last.Column != 1 {
+		t.Errorf("token after heredoc wrong. got=%+v", last)
+	}
+}
+
+func TestNextTokenUnterminatedHeredoc(t *testing.T) {
+	tests := []string{
+		"let s = <<END\nnever closed\n",
+		"let s = <<END",
+	}
+
+	for _, input := range tests {
+		l := New(input)
+
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			if tok.Type == token.IDENT && tok.Literal == "closed" {
+				t.Errorf("%q: heredoc body was tokenized", input)
+			}
+		}
+
+		expected := []string{"unterminated heredoc, missing terminator END"}
+		if len(l.Errors()) != 1 || l.Errors()[0] != expected[0] {
+			t.Errorf("%q: errors wrong. expected=%q, got=%q", input, expected, l.Errors())
+		}
+	}
+}
+
+func TestNewReaderMatchesNew(t *testing.T) {
+	tests := []string{
+		"let five = 5;\nlet add = fn(x, y) { x + y; };\n",
+		"a == b != c <= d >= e && f || g ?? h |> i",
+		"\"caf\u00e9\" \"\\t\" 1.5 0x1F 017",
+		"let s = <<END\n  a\n  b\nEND\nx",
+		"let s = <<~END\n    a\n  END\ny",
+		"/ab+c/i.test(s)",
+		"let s = <<END\nnever closed",
+		"",
+	}
+
+	for _, input := range tests {
+		expected := collectTokens(New(input))
+		got := collectTokens(NewReader(strings.NewReader(input)))
+
+		if len(got) != len(expected) {
+			t.Errorf("%q: wrong number of tokens. expected=%d, got=%d", input, len(expected), len(got))
+			continue
+		}
+		for i := range expected {
+			if got[i] != expected[i] {
+				t.Errorf("%q: tokens[%d] wrong. expected=%+v, got=%+v", input, i, expected[i], got[i])
+			}
+		}
+	}
+}
+
+func TestNewReaderReadsLazily(t *testing.T) {
+	r := &countingReader{reader: strings.NewReader("let x = 1; let y = 2;")}
+	l := NewReader(r)
+
+	l.NextToken()
+	l.NextToken()
+
+	if r.read > len("let x ") {
+		t.Errorf("lexer read too far ahead. got=%d runes", r.read)
+	}
+}
+
+func TestNewReaderReadError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("let x"), &failingReader{})
+	l := NewReader(bufio.NewReader(r))
+
+	tokens := collectTokens(l)
+	if len(tokens) != 3 || tokens[1].Literal != "x" {
+		t.Fatalf("tokens wrong. got=%+v", tokens)
+	}
+
+	expected := []string{"read error: disk on fire"}
+	if len(l.Errors()) != 1 || l.Errors()[0] != expected[0] {
+		t.Errorf("errors wrong. expected=%q, got=%q", expected, l.Errors())
+	}
+}
+
+func TestNewReaderDiscardsReadInput(t *testing.T) {
+	line := "let x = <<END\n  text\nEND\nif (x) {\n  y / 2\n}\n"
+	input := strings.Repeat(line, 10000)
+
+	for _, options := range [][]Option{nil, {WithNewlines()}, {WithIndentation()}} {
+		expected := New(input, options...)
+		l := NewReader(strings.NewReader(input), options...)
+
+		longest := 0
+		for i := 0; ; i++ {
+			want, tok := expected.NextToken(), l.NextToken()
+			if tok != want {
+				t.Fatalf("tokens[%d] wrong. expected=%+v, got=%+v", i, want, tok)
+			}
+			longest = max(longest, len(l.src.buf))
+			if tok.Type == token.EOF {
+				break
+			}
+		}
+
+		if longest > 4*len(line) {
+			t.Errorf("lexer kept too much input. got=%d bytes of %d", longest, len(input))
+		}
+	}
+}
+
+func TestNewReaderTokensKeepsInput(t *testing.T) {
+	input := strings.Repeat("let x = 1;\n", 1000)
+	l := NewReader(strings.NewReader(input))
+	l.NextToken()
+
+	if tokens := l.Tokens(); len(tokens) != 5*1000 {
+		t.Fatalf("Tokens() wrong length. got=%d", len(tokens))
+	}
+
+	expected := New(input)
+	expected.NextToken()
+	for i := 0; ; i++ {
+		want, tok := expected.NextToken(), l.NextToken()
+		if tok != want {
+			t.Fatalf("tokens[%d] after Tokens() wrong. expected=%+v, got=%+v", i, want, tok)
+		}
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+}
+
+func collectTokens(l *Lexer) []token.Token {
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+type countingReader struct {
+	reader io.RuneReader
+	read   int
+}
+
+func (r *countingReader) ReadRune() (rune, int, error) {
+	ch, size, err := r.reader.ReadRune()
+	if err == nil {
+		r.read++
+	}
+	return ch, size, err
+}
+
+type failingReader struct{}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("disk on fire")
+}
@@ -0,0 +1,113 @@
+package lexer
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// source holds the input read so far. It is shared by copies of a Lexer,
+// so input read while scanning ahead on a copy is not lost.
+//
+// Positions are offsets into the whole input. Input read from a reader is
+// only kept from the last position passed to discard on, so the lexer needs
+// memory for its current line and not for the whole input.
+type source struct {
+	reader io.RuneReader // nil once the input is complete
+	buf    []byte        // the input from base on
+	base   int           // position of buf[0] in the input
+	err    error         // the read error that ended the input early, if any
+
+	input     string // the whole input unless streaming
+	streaming bool   // the input comes from a reader and can be discarded
+	pins      int    // number of scans that need all input kept
+}
+
+func newStringSource(input string) *source {
+	return &source{buf: []byte(input), input: input}
+}
+
+func newReaderSource(r io.RuneReader) *source {
+	return &source{reader: r, streaming: true}
+}
+
+// readRune appends the next rune of the reader and reports whether there
+// was one.
+func (s *source) readRune() bool {
+	if s.reader == nil {
+		return false
+	}
+
+	r, _, err := s.reader.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		s.reader = nil
+		return false
+	}
+
+	s.buf = utf8.AppendRune(s.buf, r)
+	return true
+}
+
+// ensure reads until the byte at position i is available and reports
+// whether it is.
+func (s *source) ensure(i int) bool {
+	for i >= s.len() {
+		if !s.readRune() {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *source) charAt(i int) byte {
+	if !s.ensure(i) {
+		return 0
+	}
+	return s.buf[i-s.base]
+}
+
+// slice returns the input from start to end, which must already be read and
+// not discarded.
+func (s *source) slice(start, end int) string {
+	if !s.streaming {
+		return s.input[start:end]
+	}
+	// copied, so a literal doesn't keep the buffer alive
+	return string(s.buf[start-s.base : end-s.base])
+}
+
+// indexByte returns the position of the first c at or after from, or -1 if
+// the input has no more c. In that case the whole input has been read.
+func (s *source) indexByte(from int, c byte) int {
+	for i := from; s.ensure(i); i++ {
+		if s.buf[i-s.base] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// len returns the number of bytes read so far.
+func (s *source) len() int {
+	return s.base + len(s.buf)
+}
+
+// discard drops the input read from a reader before position keep, unless
+// a scan pinned it. The buffer is only compacted once at least half of it
+// can be dropped, so discarding costs amortized constant time.
+func (s *source) discard(keep int) {
+	if !s.streaming || s.pins > 0 {
+		return
+	}
+
+	drop := min(keep-s.base, len(s.buf))
+	if drop <= 0 || drop < len(s.buf)/2 {
+		return
+	}
+
+	n := copy(s.buf, s.buf[drop:])
+	s.buf = s.buf[:n]
+	s.base = keep
+}
@@ -1,16 +1,26 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
+	"os"
 	"strconv"
+	"strings"
+	"unicode"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // x = y
+	PIPE        // |>
+	OR          // ||
+	COALESCE    // ??
+	AND         // &&
+	RANGE       // 1..10
 	EQUALS      // ==
 	LESSGREATER // < or >
 	SUM         // +
@@ -20,21 +30,79 @@ const (
 	INDEX       // array[index]
 )
 
+const DefaultMaxErrors = 100
+
+const DefaultMaxDepth = 1000
+
+// DefaultBuiltins are the names of the evaluator's builtin functions.
+var DefaultBuiltins = ast.Builtins
+
 type Parser struct {
-	lexer  *lexer.Lexer
-	errors []string
+	lexer       *lexer.Lexer
+	errors      []Error
+	warnings    []Warning
+	lexerErrors int       // number of lexer errors already reported
+	comments    []comment // comments not yet attached to a statement
+
+	// MaxErrors caps the number of collected errors. When another error
+	// comes after that many, the last one is replaced by a TooManyErrors
+	// error and parsing is aborted, so a program with exactly MaxErrors
+	// errors keeps all of them. A value <= 0 disables the limit.
+	MaxErrors int
+	aborted   bool
+
+	// MaxDepth limits how deeply expressions may nest before parsing is
+	// aborted, protecting against stack overflows. A value <= 0 disables
+	// the limit.
+	MaxDepth int
+	depth    int
+
+	// MaxElements limits the number of elements in a list and of pairs in a
+	// hash literal before parsing is aborted. A value <= 0 disables the
+	// limit.
+	MaxElements int
+
+	// StrictComparisons reports chained comparisons like `1 < x < 10` as
+	// errors instead of warnings.
+	StrictComparisons bool
+
+	// FoldConstants collapses arithmetic on two integer literals into a
+	// single integer literal, so `2 + 3` parses as `5`.
+	FoldConstants bool
+
+	// WarnBuiltinShadow warns about let bindings and function parameters
+	// that shadow one of Builtins.
+	WarnBuiltinShadow bool
+	Builtins          map[string]bool
+
+	// Terminators are the tokens that end a statement, by default a ';'.
+	// The token.NEWLINE emitted by lexer.WithNewlines always ends one too.
+	Terminators map[token.TokenType]bool
 
 	curToken  token.Token
 	peekToken token.Token
 
-	prefixParseFn map[token.TokenType]prefixParseFn
-	infixParseFn  map[token.TokenType]infixParseFn
+	noTrailingBlock bool // a '{' after a call starts a block, not a trailing lambda
+
+	prefixParseFn    map[token.TokenType]prefixParseFn
+	infixParseFn     map[token.TokenType]infixParseFn
+	statementParseFn map[token.TokenType]func() ast.Statement
+	precedences      map[token.TokenType]int
 }
 
 func New(lexer *lexer.Lexer) *Parser {
 	parser := &Parser{
-		lexer:  lexer,
-		errors: []string{},
+		lexer:     lexer,
+		errors:    []Error{},
+		MaxErrors: DefaultMaxErrors,
+		MaxDepth:  DefaultMaxDepth,
+		Builtins:  make(map[string]bool, len(DefaultBuiltins)),
+
+		Terminators: map[token.TokenType]bool{token.SEMICOLON: true},
+	}
+
+	for _, name := range DefaultBuiltins {
+		parser.Builtins[name] = true
 	}
 
 	// Read two tokens, so curToken and peekToken are both set
@@ -43,427 +111,2527 @@ func New(lexer *lexer.Lexer) *Parser {
 
 	parser.prefixParseFn = make(map[token.TokenType]prefixParseFn)
 	parser.registerPrefixFn(token.IDENT, parser.parseIdentifier)
+	parser.registerPrefixFn(token.PRINT, parser.parseIdentifier)
 	parser.registerPrefixFn(token.INT, parser.parseIntegerLiteral)
+	parser.registerPrefixFn(token.FLOAT, parser.parseFloatLiteral)
 	parser.registerPrefixFn(token.BANG, parser.parsePrefixExpression)
 	parser.registerPrefixFn(token.MINUS, parser.parsePrefixExpression)
+	parser.registerPrefixFn(token.NOT_KW, parser.parsePrefixExpression)
+	parser.registerPrefixFn(token.TYPEOF, parser.parseTypeofExpression)
 	parser.registerPrefixFn(token.TRUE, parser.parseBoolean)
 	parser.registerPrefixFn(token.FALSE, parser.parseBoolean)
 	parser.registerPrefixFn(token.LPAREN, parser.parseGroupedExpression)
 	parser.registerPrefixFn(token.IF, parser.parseIfExpression)
+	parser.registerPrefixFn(token.UNLESS, parser.parseUnlessExpression)
+	parser.registerPrefixFn(token.WHILE, parser.parseWhileExpression)
+	parser.registerPrefixFn(token.DO, parser.parseDoWhileExpression)
+	parser.registerPrefixFn(token.FOR, parser.parseForInExpression)
 	parser.registerPrefixFn(token.FUNCTION, parser.parseFunctionLiteral)
+	parser.registerPrefixFn(token.MACRO, parser.parseMacroLiteral)
+	parser.registerPrefixFn(token.TRY, parser.parseTryExpression)
 	parser.registerPrefixFn(token.STRING, parser.parseStringLiteral)
+	parser.registerPrefixFn(token.BYTES, parser.parseBytesLiteral)
+	parser.registerPrefixFn(token.REGEX, parser.parseRegexLiteral)
 	parser.registerPrefixFn(token.LBRACKET, parser.parseArrayLiteral)
-	parser.registerPrefixFn(token.LBRACE, parser.parseHashLiteral)
+	parser.registerPrefixFn(token.LBRACE, parser.parseBraceExpression)
+	parser.registerPrefixFn(token.STRUCT, parser.parseStructLiteral)
+	parser.registerPrefixFn(token.MATCH, parser.parseMatchExpression)
+
+	parser.statementParseFn = make(map[token.TokenType]func() ast.Statement)
+
+	parser.precedences = make(map[token.TokenType]int, len(precedences))
+	for tokenType, precedence := range precedences {
+		parser.precedences[tokenType] = precedence
+	}
 
 	parser.infixParseFn = make(map[token.TokenType]infixParseFn)
+	parser.registerInfixFn(token.ASSIGN, parser.parseAssignExpression)
 	parser.registerInfixFn(token.PLUS, parser.parseInfixExpression)
 	parser.registerInfixFn(token.MINUS, parser.parseInfixExpression)
+	parser.registerInfixFn(token.CONCAT, parser.parseInfixExpression)
 	parser.registerInfixFn(token.SLASH, parser.parseInfixExpression)
 	parser.registerInfixFn(token.ASTERISK, parser.parseInfixExpression)
 	parser.registerInfixFn(token.EQ, parser.parseInfixExpression)
 	parser.registerInfixFn(token.NOT_EQ, parser.parseInfixExpression)
 	parser.registerInfixFn(token.LT, parser.parseInfixExpression)
 	parser.registerInfixFn(token.GT, parser.parseInfixExpression)
+	parser.registerInfixFn(token.IS, parser.parseTypeCheckExpression)
+	parser.registerInfixFn(token.AND, parser.parseInfixExpression)
+	parser.registerInfixFn(token.OR, parser.parseInfixExpression)
+	parser.registerInfixFn(token.AND_KW, parser.parseInfixExpression)
+	parser.registerInfixFn(token.OR_KW, parser.parseInfixExpression)
+	parser.registerInfixFn(token.NULLCOALESCE, parser.parseInfixExpression)
 	parser.registerInfixFn(token.LPAREN, parser.parseCallExpression)
 	parser.registerInfixFn(token.LBRACKET, parser.parseIndexExpression)
+	parser.registerInfixFn(token.DOT, parser.parseDotExpression)
+	parser.registerInfixFn(token.QUESTIONDOT, parser.parseOptionalIndexExpression)
+	parser.registerInfixFn(token.BANG, parser.parseNonNullAssertion)
+	parser.registerInfixFn(token.PIPE, parser.parsePipeExpression)
+	parser.registerInfixFn(token.DOTDOT, parser.parseRangeExpression)
+	parser.registerInfixFn(token.DOTDOTLT, parser.parseRangeExpression)
 
 	return parser
 }
 
-var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
-}
+// Reset prepares the parser to parse the input of lexer, reusing the already
+// registered parse functions and precedences.
+func (p *Parser) Reset(lexer *lexer.Lexer) {
+	p.lexer = lexer
+	p.errors = []Error{}
+	p.warnings = nil
+	p.comments = nil
+	p.lexerErrors = 0
+	p.aborted = false
+	p.depth = 0
+	p.noTrailingBlock = false
 
-func (parser *Parser) Errors() []string {
-	return parser.errors
+	p.nextToken()
+	p.nextToken()
 }
 
-func (parser *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, parser.peekToken.Type)
-	parser.errors = append(parser.errors, msg)
+var precedences = map[token.TokenType]int{
+	token.ASSIGN:       ASSIGN,
+	token.PIPE:         PIPE,
+	token.OR:           OR,
+	token.OR_KW:        OR,
+	token.NULLCOALESCE: COALESCE,
+	token.AND:          AND,
+	token.AND_KW:       AND,
+	token.DOTDOT:       RANGE,
+	token.DOTDOTLT:     RANGE,
+	token.EQ:           EQUALS,
+	token.NOT_EQ:       EQUALS,
+	token.LT:           LESSGREATER,
+	token.GT:           LESSGREATER,
+	token.IS:           LESSGREATER,
+	token.PLUS:         SUM,
+	token.MINUS:        SUM,
+	token.CONCAT:       SUM,
+	token.SLASH:        PRODUCT,
+	token.ASTERISK:     PRODUCT,
+	token.LPAREN:       CALL,
+	token.LBRACKET:     INDEX,
+	token.DOT:          INDEX,
+	token.QUESTIONDOT:  INDEX,
+	token.BANG:         INDEX,
 }
 
-func (parser *Parser) nextToken() {
-	parser.curToken = parser.peekToken
-	parser.peekToken = parser.lexer.NextToken()
+// Errors returns the messages of the collected errors.
+func (parser *Parser) Errors() []string {
+	messages := make([]string, len(parser.errors))
+	for i, err := range parser.errors {
+		messages[i] = err.Message
+	}
+	return messages
 }
 
-func (parser *Parser) ParseProgram() *ast.Program {
-	program := &ast.Program{}
-	program.Statements = []ast.Statement{}
+// DetailedErrors returns the collected errors with their kind and position.
+func (p *Parser) DetailedErrors() []Error {
+	return p.errors
+}
 
-	for !parser.curTokenIs(token.EOF) {
-		stmt := parser.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
-		}
-		parser.nextToken()
+func (parser *Parser) peekError(t token.TokenType) {
+	msg := fmt.Sprintf("expected next token to be %s, got '%s' (%s) instead",
+		t, parser.peekToken.Literal, parser.peekToken.Type)
+	if keyword, ok := suggestKeyword(parser.peekToken); ok && token.LookupIdent(keyword) == t {
+		msg += fmt.Sprintf(", did you mean `%s`?", keyword)
 	}
-
-	return program
+	parser.addErrorKind(parser.peekToken, UnexpectedToken, msg)
 }
 
-func (parser *Parser) parseStatement() ast.Statement {
-	switch parser.curToken.Type {
-	case token.LET:
-		return parser.parseLetStatement()
-	case token.RETURN:
-		return parser.parseReturnStatement()
-	default:
-		return parser.parseExpressionStatement()
-	}
+func (parser *Parser) addError(msg string) {
+	parser.addErrorAt(parser.curToken, msg)
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+func (p *Parser) addErrorAt(tok token.Token, msg string) {
+	p.addErrorKind(tok, OtherError, msg)
+}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
+func (p *Parser) addErrorKind(tok token.Token, kind ErrorKind, msg string) {
+	if p.aborted {
+		return
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-
-	if !p.expectPeek(token.ASSIGN) {
-		return nil
+	if p.MaxErrors > 0 && len(p.errors) >= p.MaxErrors {
+		p.errors = p.errors[:p.MaxErrors-1]
+		msg = "too many errors, aborting"
+		kind = TooManyErrors
+		p.aborted = true
 	}
 
-	p.nextToken()
-
-	stmt.Value = p.parseExpression(LOWEST)
-
-	if p.peekTokenIs(token.SEMICOLON) {
-		p.nextToken()
-	}
+	p.errors = append(p.errors, Error{Message: msg, Line: tok.Line, Column: tok.Column, Kind: kind})
+}
 
-	return stmt
+// Warnings returns the non-fatal diagnostics, like a binding that shadows a
+// builtin. Unlike errors they don't mean that parsing failed.
+func (p *Parser) Warnings() []Warning {
+	return p.warnings
 }
 
-func (parser *Parser) expectPeek(t token.TokenType) bool {
-	if parser.peekTokenIs(t) {
-		parser.nextToken()
-		return true
-	} else {
-		parser.peekError(t)
-		return false
-	}
+func (p *Parser) addWarning(tok token.Token, msg string) {
+	p.warnings = append(p.warnings, Warning{Message: msg, Line: tok.Line, Column: tok.Column})
 }
 
-func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
-	stmt := &ast.ReturnStatement{Token: p.curToken}
+// ErrorsDetailed renders every error with its position, the source line of
+// src it occurs in and a caret pointing at the offending column.
+func (p *Parser) ErrorsDetailed(src string) string {
+	var out strings.Builder
+	lines := strings.Split(src, "\n")
 
-	p.nextToken()
+	for _, err := range p.errors {
+		fmt.Fprintf(&out, "%d:%d: %s\n", err.Line, err.Column, err.Message)
 
-	stmt.ReturnValue = p.parseExpression(LOWEST)
+		if err.Line < 1 || err.Line > len(lines) {
+			continue
+		}
 
-	if p.peekTokenIs(token.SEMICOLON) {
-		p.nextToken()
+		line := strings.TrimSuffix(lines[err.Line-1], "\r")
+		out.WriteString(line)
+		out.WriteString("\n")
+
+		// keep tabs so the caret lines up with the source line
+		for j := 0; j < err.Column-1 && j < len(line); j++ {
+			if line[j] == '\t' {
+				out.WriteByte('\t')
+			} else {
+				out.WriteByte(' ')
+			}
+		}
+		out.WriteString("^\n")
 	}
 
-	return stmt
+	return out.String()
 }
 
-func (parser *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	stmt := &ast.ExpressionStatement{Token: parser.curToken}
+func (parser *Parser) nextToken() {
+	parser.curToken = parser.peekToken
+	parser.peekToken = parser.lexer.NextToken()
 
-	stmt.Expression = parser.parseExpression(LOWEST)
+	for parser.peekToken.Type == token.COMMENT {
+		ownLine := parser.peekToken.Line > parser.curToken.Line
+		parser.comments = append(parser.comments, comment{Token: parser.peekToken, ownLine: ownLine})
+		parser.peekToken = parser.lexer.NextToken()
+	}
 
-	if parser.peekTokenIs(token.SEMICOLON) {
-		parser.nextToken()
+	for _, msg := range parser.lexer.Errors()[parser.lexerErrors:] {
+		parser.addErrorKind(parser.peekToken, InvalidString, msg)
+		parser.lexerErrors++
 	}
+}
 
-	return stmt
+type comment struct {
+	token.Token
+	ownLine bool // no other token precedes the comment on its line
 }
 
-func (parser *Parser) parseExpression(precedence int) ast.Expression {
-	prefix := parser.prefixParseFn[parser.curToken.Type]
-	if prefix == nil {
-		parser.noPrefixPerseFnErrror(parser.curToken.Type)
-		return nil
+// scanAhead returns a function that returns the tokens following the peek
+// token one by one, skipping comments. It uses the lexer's PeekN, so no
+// tokens are consumed.
+func (p *Parser) scanAhead() func() token.Token {
+	n := 0
+	return func() token.Token {
+		for {
+			n++
+			if tok := p.lexer.PeekN(n); tok.Type != token.COMMENT {
+				return tok
+			}
+		}
 	}
+}
 
-	leftExpression := prefix()
-	for !parser.peekTokenIs(token.SEMICOLON) && precedence < parser.peekPrecedence() {
-		infix := parser.infixParseFn[parser.peekToken.Type]
-		if infix == nil {
-			return leftExpression
-		}
+func (parser *Parser) ParseProgram() *ast.Program {
+	program := &ast.Program{}
+	program.Statements = []ast.Statement{}
 
+	for !parser.curTokenIs(token.EOF) && !parser.aborted {
+		stmt := parser.parseStatement()
+		if stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
 		parser.nextToken()
-		leftExpression = infix(leftExpression)
-
 	}
 
-	return leftExpression
+	return program
 }
 
-func (parser *Parser) noPrefixPerseFnErrror(tokenType token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", tokenType)
-	parser.errors = append(parser.errors, msg)
-}
+// ParseProgramE parses the program like ParseProgram, but returns the
+// collected parser errors as a *ParseError instead of leaving them to be
+// checked via Errors().
+func (p *Parser) ParseProgramE() (*ast.Program, error) {
+	program := p.ParseProgram()
+	if len(p.errors) > 0 {
+		return program, &ParseError{Errors: p.DetailedErrors()}
+	}
 
-func (parser *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+	return program, nil
 }
 
-func (parser *Parser) parseIntegerLiteral() ast.Expression {
-	integerLiteral := &ast.IntegerLiteral{Token: parser.curToken}
+// ParseProgramStrict parses the program like ParseProgram, but stops after
+// the statement holding the first error. That error is returned prefixed
+// with its position, e.g. "3:7: ...", and wraps the Error. The program
+// holds the statements parsed before it.
+func (p *Parser) ParseProgramStrict() (*ast.Program, error) {
+	program := &ast.Program{}
+	program.Statements = []ast.Statement{}
 
-	value, err := strconv.ParseInt(parser.curToken.Literal, 0, 64)
-	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", parser.curToken.Literal)
-		parser.errors = append(parser.errors, msg)
+	for !p.curTokenIs(token.EOF) {
+		stmt := p.parseStatement()
+		if len(p.errors) > 0 {
+			first := p.errors[0]
+			return program, fmt.Errorf("%d:%d: %w", first.Line, first.Column, first)
+		}
+		if stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
+		p.nextToken()
 	}
 
-	integerLiteral.Value = value
-
-	return integerLiteral
+	return program, nil
 }
 
-func (parser *Parser) parsePrefixExpression() ast.Expression {
-	expression := &ast.PrefixExpression{
-		Token:    parser.curToken,
-		Operator: parser.curToken.Literal,
+// ParseFile reads and parses the file filename. Parser errors are returned
+// as a *ParseError for filename, rendered like "main.monkey:3:7: ...".
+func ParseFile(filename string) (*ast.Program, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
 	}
 
-	parser.nextToken()
-
-	expression.Right = parser.parseExpression(PREFIX)
+	p := New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.errors) == 0 {
+		return program, nil
+	}
 
-	return expression
+	return program, &ParseError{Filename: filename, Errors: p.DetailedErrors()}
 }
 
-type (
-	prefixParseFn func() ast.Expression
-	infixParseFn  func(ast.Expression) ast.Expression
-)
-
-func (parser *Parser) registerPrefixFn(tokkenType token.TokenType, fn prefixParseFn) {
-	parser.prefixParseFn[tokkenType] = fn
+// ParseError holds the errors of a failed parse. Error renders one line per
+// error, prefixed with the filename and position when Filename is set.
+type ParseError struct {
+	Filename string // empty unless the input was read from a file
+	Errors   []Error
 }
 
-func (parser *Parser) registerInfixFn(tokkenType token.TokenType, fn infixParseFn) {
-	parser.infixParseFn[tokkenType] = fn
+func (e *ParseError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		if e.Filename == "" {
+			lines[i] = err.Message
+		} else {
+			lines[i] = fmt.Sprintf("%s:%d:%d: %s", e.Filename, err.Line, err.Column, err.Message)
+		}
+	}
+	return strings.Join(lines, "\n")
 }
 
-func (parser *Parser) getPrecedence(tokenType token.TokenType) int {
-	precedence, ok := precedences[tokenType]
+type ErrorKind int
 
-	if ok {
-		return precedence
-	}
+const (
+	OtherError      ErrorKind = iota
+	UnexpectedToken           // a different token was expected
+	NoPrefixParseFn           // a token cannot start an expression
+	InvalidInteger            // an integer literal is malformed or out of range
+	InvalidFloat              // a float literal is malformed or out of range
+	InvalidString             // a string literal is malformed or not valid UTF-8
+	TooDeep                   // expressions nest deeper than MaxDepth
+	TooManyElements           // a list or hash literal exceeds MaxElements
+	TooManyErrors             // MaxErrors was reached and parsing aborted
+)
 
-	return LOWEST
+// Error is a parser error together with the position of the token it
+// points at.
+type Error struct {
+	Message string
+	Line    int
+	Column  int
+	Kind    ErrorKind
 }
 
-func (parser *Parser) peekPrecedence() int {
-	return parser.getPrecedence(parser.peekToken.Type)
+func (e Error) Error() string {
+	return e.Message
 }
 
-func (parser *Parser) curPrecendence() int {
-	return parser.getPrecedence(parser.curToken.Type)
+// Warning is a non-fatal diagnostic together with the position of the token
+// it points at.
+type Warning struct {
+	Message string
+	Line    int
+	Column  int
 }
 
-func (parser *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	expression := &ast.InfixExpression{
-		Token:    parser.curToken,
-		Operator: parser.curToken.Literal,
-		Left:     left,
-	}
-
-	precedence := parser.curPrecendence()
-	parser.nextToken()
-	expression.Right = parser.parseExpression(precedence)
+func (p *Parser) parseStatement() ast.Statement {
+	leading := p.takeComments(func(c comment) bool { return c.ownLine })
+	p.comments = p.comments[:0]
 
-	return expression
-}
+	stmt := p.parseStatementNode()
 
-func (parser *Parser) curTokenIs(t token.TokenType) bool {
-	return parser.curToken.Type == t
-}
+	if comments := ast.CommentsOf(stmt); comments != nil {
+		end := p.curToken
+		trailing := p.takeComments(func(c comment) bool {
+			return c.Line == end.Line && c.Column > end.Column
+		})
 
-func (parser *Parser) peekTokenIs(t token.TokenType) bool {
-	return parser.peekToken.Type == t
-}
+		comments.LeadingComment = leading
+		comments.TrailingComment = trailing
+	}
 
-func (parser *Parser) parseBoolean() ast.Expression {
-	return &ast.Boolean{Token: parser.curToken, Value: parser.curTokenIs(token.TRUE)}
+	return stmt
 }
 
-func (parser *Parser) parseGroupedExpression() ast.Expression {
-	parser.nextToken()
-
-	expression := parser.parseExpression(LOWEST)
-
-	if !parser.expectPeek(token.RPAREN) {
-		return nil
+// takeComments removes the pending comments matched by include and returns
+// their text, one line per comment.
+func (p *Parser) takeComments(include func(comment) bool) string {
+	lines := []string{}
+	pending := p.comments[:0]
+
+	for _, c := range p.comments {
+		if include(c) {
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(c.Literal, "//")))
+		} else {
+			pending = append(pending, c)
+		}
 	}
+	p.comments = pending
 
-	return expression
+	return strings.Join(lines, "\n")
 }
 
-func (p *Parser) parseIfExpression() ast.Expression {
-	expression := &ast.IfExpression{Token: p.curToken}
-
-	if !p.expectPeek(token.LPAREN) {
-		return nil
+func (parser *Parser) parseStatementNode() ast.Statement {
+	if fn, ok := parser.statementParseFn[parser.curToken.Type]; ok {
+		return fn()
 	}
 
-	p.nextToken()
-	expression.Condition = p.parseExpression(LOWEST)
-
-	if !p.expectPeek(token.RPAREN) {
+	// an empty statement, like a blank line
+	if parser.isTerminator(parser.curToken) {
 		return nil
 	}
 
-	if !p.expectPeek(token.LBRACE) {
+	switch parser.curToken.Type {
+	case token.LET, token.CONST:
+		return parser.parseLetStatement()
+	case token.RETURN:
+		return parser.parseReturnStatement()
+	case token.YIELD:
+		return parser.parseYieldStatement()
+	case token.AT:
+		return parser.parseDecoratedStatement()
+	case token.BREAK:
+		return parser.parseBreakStatement()
+	case token.CONTINUE:
+		return parser.parseContinueStatement()
+	case token.IMPORT:
+		return parser.parseImportStatement()
+	case token.EXPORT:
+		return parser.parseExportStatement()
+	case token.INTERFACE:
+		return parser.parseInterfaceDeclaration()
+	case token.PRINT:
+		if parser.peekTokenIs(token.LPAREN) {
+			return parser.parseExpressionStatement()
+		}
+		return parser.parsePrintStatement()
+	case token.NEWLINE:
 		return nil
+	case token.IDENT:
+		if parser.isScopeModifier() {
+			return parser.parseScopedAssignStatement()
+		}
+		if parser.peekTokenIs(token.COLON) {
+			return parser.parseLabeledStatement()
+		}
+		if parser.peekTokenIs(token.COMMA) && parser.isMultiAssign() {
+			return parser.parseMultiAssignStatement()
+		}
+		return parser.parseExpressionStatement()
+	default:
+		return parser.parseExpressionStatement()
 	}
+}
 
-	expression.Consequence = p.parseBlockStatement()
+func (p *Parser) parseLetStatement() ast.Statement {
+	stmt := &ast.LetStatement{Token: p.curToken, IsConst: p.curTokenIs(token.CONST)}
 
-	if p.peekTokenIs(token.ELSE) {
+	switch {
+	case p.peekTokenIs(token.LBRACKET):
+		p.nextToken()
+		stmt.Pattern = p.parseArrayPattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	case p.peekTokenIs(token.LBRACE):
 		p.nextToken()
+		stmt.Pattern = p.parseHashPattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	default:
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.checkBuiltinShadow(stmt.Name)
+	}
 
-		if !p.expectPeek(token.LBRACE) {
+	if stmt.Pattern != nil || stmt.IsConst || !p.peekLetBindingEnd() {
+		if !p.expectPeek(token.ASSIGN) {
 			return nil
 		}
 
-		expression.Alternative = p.parseBlockStatement()
+		p.nextToken()
+		stmt.Value = p.parseExpression(LOWEST)
 	}
 
-	return expression
-}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
 
-func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{Token: p.curToken}
-	block.Statements = []ast.Statement{}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
 
-	p.nextToken()
+		binding := ast.LetBinding{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+		p.checkBuiltinShadow(binding.Name)
 
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		statement := p.parseStatement()
-		if statement != nil {
-			block.Statements = append(block.Statements, statement)
+		if stmt.IsConst || !p.peekLetBindingEnd() {
+			if !p.expectPeek(token.ASSIGN) {
+				return nil
+			}
+
+			p.nextToken()
+			binding.Value = p.parseExpression(LOWEST)
 		}
+
+		stmt.Additional = append(stmt.Additional, binding)
+	}
+
+	if p.peekTerminator() {
 		p.nextToken()
 	}
 
-	return block
+	return stmt
 }
 
-func (p *Parser) parseFunctionLiteral() ast.Expression {
-	lit := &ast.FunctionLiteral{Token: p.curToken}
-
-	if !p.expectPeek(token.LPAREN) {
-		return nil
+func (p *Parser) checkBuiltinShadow(name *ast.Identifier) {
+	if p.WarnBuiltinShadow && p.Builtins[name.Value] {
+		p.addWarning(name.Token, fmt.Sprintf("%s shadows a builtin", name.Value))
 	}
+}
 
-	lit.Parameters = p.parseFunctionParameters()
+// peekLetBindingEnd reports whether a let binding ends without initializer.
+func (p *Parser) peekLetBindingEnd() bool {
+	return p.peekTerminator() || p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.EOF)
+}
 
-	if !p.expectPeek(token.LBRACE) {
-		return nil
+func (p *Parser) parseArrayPattern() ast.Expression {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
 	}
 
-	lit.Body = p.parseBlockStatement()
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
 
-	return lit
+	return pattern
+}
+
+func (p *Parser) parseHashPattern() ast.Expression {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		pattern.Keys = append(pattern.Keys, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return pattern
+}
+
+func (parser *Parser) expectPeek(t token.TokenType) bool {
+	if parser.peekTokenIs(t) {
+		parser.nextToken()
+		return true
+	} else {
+		parser.peekError(t)
+		return false
+	}
+}
+
+func (p *Parser) parseReturnStatement() ast.Statement {
+	stmt := &ast.ReturnStatement{Token: p.curToken}
+
+	// A bare `return` ends its block early without a value.
+	if p.peekTerminator() || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) {
+		if p.peekTerminator() {
+			p.nextToken()
+		}
+		return stmt
+	}
+
+	p.nextToken()
+
+	stmt.ReturnValue = p.parseExpression(LOWEST)
+
+	guarded := p.parseStatementModifier(stmt)
+
+	if p.peekTerminator() {
+		p.nextToken()
+	}
+
+	return guarded
+}
+
+// parseStatementModifier wraps stmt in an ast.GuardedStatement when it is
+// followed by `if <condition>` or `unless <condition>` on the same line.
+func (p *Parser) parseStatementModifier(stmt ast.Statement) ast.Statement {
+	if !p.peekTokenIs(token.IF) && !p.peekTokenIs(token.UNLESS) || p.peekToken.Line != p.curToken.Line {
+		return stmt
+	}
+
+	p.nextToken()
+	guarded := &ast.GuardedStatement{Token: p.curToken, Statement: stmt, Negate: p.curTokenIs(token.UNLESS)}
+
+	p.nextToken()
+	guarded.Condition = p.parseExpression(LOWEST)
+	if guarded.Condition == nil {
+		return nil
+	}
+
+	return guarded
+}
+
+// parsePrintStatement parses the parenless `print a, b;`. A `print(...)` is
+// parsed as a regular call instead.
+func (p *Parser) parsePrintStatement() ast.Statement {
+	stmt := &ast.PrintStatement{Token: p.curToken, Args: []ast.Expression{}}
+
+	if !p.peekTerminator() && !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
+		stmt.Args = append(stmt.Args, p.parseExpression(LOWEST))
+		for p.peekTokenIs(token.COMMA) {
+			if p.tooManyElements(len(stmt.Args), "arguments in print statement") {
+				return nil
+			}
+
+			p.nextToken()
+			p.nextToken()
+			stmt.Args = append(stmt.Args, p.parseExpression(LOWEST))
+		}
+	}
+
+	if p.peekTerminator() {
+		p.nextToken()
+	}
+
+	if hasNil(stmt.Args...) {
+		return nil
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseInterfaceDeclaration() ast.Statement {
+	stmt := &ast.InterfaceDeclaration{Token: p.curToken, Methods: []ast.MethodSignature{}}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.skipPeekNewlines()
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.FUNCTION) {
+			return nil
+		}
+
+		method, ok := p.parseMethodSignature()
+		if !ok {
+			return nil
+		}
+		stmt.Methods = append(stmt.Methods, method)
+
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		p.skipPeekNewlines()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return stmt
+}
+
+// parseMethodSignature parses `fn name(a: Type, b): Type` inside an
+// interface. Methods must not have a body.
+func (p *Parser) parseMethodSignature() (ast.MethodSignature, bool) {
+	method := ast.MethodSignature{Token: p.curToken, Parameters: []ast.TypedParameter{}}
+
+	if !p.expectPeek(token.IDENT) {
+		return method, false
+	}
+	method.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LPAREN) {
+		return method, false
+	}
+
+	for !p.peekTokenIs(token.RPAREN) {
+		if !p.expectPeek(token.IDENT) {
+			return method, false
+		}
+
+		parameter := ast.TypedParameter{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return method, false
+			}
+			parameter.Type = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		}
+		method.Parameters = append(method.Parameters, parameter)
+
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return method, false
+		}
+	}
+	p.nextToken()
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return method, false
+		}
+		method.ReturnType = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if p.peekTokenIs(token.LBRACE) {
+		msg := fmt.Sprintf("interface method %s must not have a body", method.Name.Value)
+		p.addErrorAt(p.peekToken, msg)
+		return method, false
+	}
+
+	return method, true
+}
+
+// parseYieldStatement parses `yield x;` or a bare `yield;`. Whether it
+// appears inside a function is not checked.
+func (p *Parser) parseYieldStatement() *ast.YieldStatement {
+	stmt := &ast.YieldStatement{Token: p.curToken}
+
+	if !p.peekTerminator() && !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
+		stmt.Value = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTerminator() {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+	stmt.Label = p.parseJumpLabel()
+
+	if p.peekTerminator() {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+	stmt.Label = p.parseJumpLabel()
+
+	if p.peekTerminator() {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseJumpLabel parses the optional label after break or continue.
+func (p *Parser) parseJumpLabel() *ast.Identifier {
+	if !p.peekTokenIs(token.IDENT) {
+		return nil
+	}
+
+	p.nextToken()
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+
+		stmt.Names = p.parseNameList()
+		if stmt.Names == nil {
+			return nil
+		}
+
+		if !p.expectPeek(token.FROM) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	stmt.Path = p.curToken.Literal
+
+	if p.peekTerminator() {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseNameList parses a `{ a, b }` name list as used by import and export.
+func (p *Parser) parseNameList() []*ast.Identifier {
+	names := []*ast.Identifier{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		names = append(names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+
+	if len(names) == 0 {
+		p.addError("name list is empty")
+		return nil
+	}
+
+	return names
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	switch p.peekToken.Type {
+	case token.LET, token.CONST:
+		p.nextToken()
+		stmt.Declaration = p.parseLetStatement()
+
+	case token.FUNCTION:
+		p.nextToken()
+		stmt.Declaration = p.parseFunctionDeclaration()
+
+	case token.LBRACE:
+		p.nextToken()
+		stmt.Names = p.parseNameList()
+		if stmt.Names == nil {
+			return nil
+		}
+
+		if p.peekTerminator() {
+			p.nextToken()
+		}
+
+		return stmt
+
+	default:
+		msg := fmt.Sprintf("expected declaration or name list after export, got '%s' (%s) instead",
+			p.peekToken.Literal, p.peekToken.Type)
+		p.addErrorAt(p.peekToken, msg)
+		return nil
+	}
+
+	if stmt.Declaration == nil {
+		return nil
+	}
+
+	return stmt
+}
+
+// parseFunctionDeclaration parses `fn name(params) { body }` into the
+// equivalent `let name = fn(params) { body };`.
+func (p *Parser) parseFunctionDeclaration() ast.Statement {
+	fnToken := p.curToken
+
+	stmt := &ast.LetStatement{Token: p.curToken}
+	stmt.Token.Type, stmt.Token.Literal = token.LET, "let"
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.checkBuiltinShadow(stmt.Name)
+
+	lit, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+	if !ok || lit == nil {
+		return nil
+	}
+	lit.Token = fnToken
+	stmt.Value = lit
+
+	if p.peekTerminator() {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseDecoratedStatement parses one or more `@name` or `@name(args)`
+// decorators and the function declaration they apply to.
+func (p *Parser) parseDecoratedStatement() ast.Statement {
+	stmt := &ast.DecoratedStatement{Token: p.curToken}
+
+	for p.curTokenIs(token.AT) {
+		decorator := ast.Decorator{Token: p.curToken}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		decorator.Expression = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			decorator.Expression = p.parseCallExpression(decorator.Expression)
+			if decorator.Expression == nil {
+				return nil
+			}
+		}
+
+		stmt.Decorators = append(stmt.Decorators, decorator)
+		p.nextToken()
+		for p.curTokenIs(token.NEWLINE) {
+			p.nextToken()
+		}
+	}
+
+	declaration := p.curToken
+	if p.curTokenIs(token.FUNCTION) && p.peekTokenIs(token.IDENT) {
+		stmt.Declaration = p.parseFunctionDeclaration()
+	} else {
+		stmt.Declaration = p.parseStatement()
+	}
+
+	// the declaration already reported why it failed to parse
+	if stmt.Declaration == nil {
+		return nil
+	}
+
+	if !isFunctionDeclaration(stmt.Declaration) {
+		p.addErrorAt(declaration, fmt.Sprintf("decorator must be followed by a function declaration, got %s", declaration.Literal))
+		return nil
+	}
+
+	return stmt
+}
+
+// isFunctionDeclaration reports whether stmt is a let binding a function
+// literal, which includes a `fn name() {}` declaration.
+func isFunctionDeclaration(stmt ast.Statement) bool {
+	let, ok := stmt.(*ast.LetStatement)
+	if !ok || len(let.Additional) > 0 {
+		return false
+	}
+	_, ok = let.Value.(*ast.FunctionLiteral)
+	return ok
+}
+
+func (p *Parser) parseLabeledStatement() ast.Statement {
+	stmt := &ast.LabeledStatement{Token: p.curToken}
+	stmt.Label = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	p.nextToken()
+	p.nextToken()
+
+	stmt.Statement = p.parseStatement()
+	if stmt.Statement == nil {
+		p.addError(fmt.Sprintf("label %s is not followed by a statement", stmt.Label))
+		return nil
+	}
+
+	return stmt
+}
+
+func (parser *Parser) parseExpressionStatement() ast.Statement {
+	stmt := &ast.ExpressionStatement{Token: parser.curToken}
+
+	// a top-level '=' makes an assignment statement instead of an expression
+	stmt.Expression = parser.parseExpression(ASSIGN)
+
+	if parser.peekTokenIs(token.ASSIGN) {
+		return parser.parseAssignStatement(stmt.Expression)
+	}
+
+	parser.checkMistypedKeyword(stmt.Expression)
+
+	guarded := parser.parseStatementModifier(stmt)
+
+	if parser.peekTerminator() {
+		parser.nextToken()
+	}
+
+	return guarded
+}
+
+func (p *Parser) parseAssignStatement(target ast.Expression) ast.Statement {
+	p.nextToken()
+	stmt := &ast.AssignStatement{Token: p.curToken, Target: target}
+
+	valid := p.checkAssignTarget(target)
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTerminator() {
+		p.nextToken()
+	}
+
+	if !valid {
+		return nil
+	}
+
+	return stmt
+}
+
+// isScopeModifier reports whether the current identifier is `outer` or
+// `global` used as a scope modifier. That is the case when it is followed on
+// the same line by something that can't continue an expression starting
+// with a name, like the name in `outer x = 5`. Otherwise it is an ordinary
+// name, like the label in `outer: while (x) { break outer; }`.
+func (p *Parser) isScopeModifier() bool {
+	if _, ok := token.LookupScopeModifier(p.curToken.Literal); !ok {
+		return false
+	}
+
+	if p.peekToken.Line != p.curToken.Line {
+		return false
+	}
+
+	_, isPrefix := p.prefixParseFn[p.peekToken.Type]
+	_, isInfix := p.infixParseFn[p.peekToken.Type]
+	return isPrefix && !isInfix
+}
+
+// parseScopedAssignStatement parses `outer x = 5;` and `global x = 5;`. It
+// only records the scope; resolving the name is left to the evaluator.
+func (p *Parser) parseScopedAssignStatement() ast.Statement {
+	stmt := &ast.ScopedAssignStatement{Token: p.curToken, Scope: ast.Outer}
+	stmt.Token.Type, _ = token.LookupScopeModifier(p.curToken.Literal)
+	if stmt.Token.Type == token.GLOBAL {
+		stmt.Scope = ast.Global
+	}
+
+	if !p.peekTokenIs(token.IDENT) {
+		p.addErrorAt(p.peekToken, fmt.Sprintf("%s must be followed by an assignment to a name, got %s", stmt.Token.Literal, p.peekToken.Literal))
+		return nil
+	}
+	p.nextToken()
+	stmt.Target = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTerminator() {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseAssignExpression parses an assignment used as an expression, like in
+// `while ((line = next()) != null)`. It is right-associative, so `a = b = c`
+// assigns c to b first.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{Token: p.curToken, Target: target}
+	valid := p.checkAssignTarget(target)
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	if !valid || expression.Value == nil {
+		return nil
+	}
+
+	return expression
+}
+
+// checkAssignTarget reports an error unless target can be assigned to.
+func (p *Parser) checkAssignTarget(target ast.Expression) bool {
+	valid := isAssignable(target)
+	if !valid && target != nil {
+		msg := fmt.Sprintf("invalid assignment target: %s", target)
+		p.addError(msg)
+	}
+
+	return valid
+}
+
+// isMultiAssign scans ahead from the current identifier to check whether it
+// starts a multi-assignment like `a, b = b, a`. No tokens are consumed.
+func (p *Parser) isMultiAssign() bool {
+	scanToken := p.scanAhead()
+
+	tok := p.peekToken
+	for tok.Type == token.COMMA {
+		if scanToken().Type != token.IDENT {
+			return false
+		}
+		tok = scanToken()
+	}
+
+	return tok.Type == token.ASSIGN
+}
+
+func (p *Parser) parseMultiAssignStatement() ast.Statement {
+	targets := []ast.Expression{p.parseIdentifier()}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		targets = append(targets, p.parseIdentifier())
+	}
+
+	p.nextToken()
+	stmt := &ast.MultiAssignStatement{Token: p.curToken, Targets: targets}
+
+	p.nextToken()
+	stmt.Values = []ast.Expression{p.parseExpression(LOWEST)}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		stmt.Values = append(stmt.Values, p.parseExpression(LOWEST))
+	}
+
+	if p.peekTerminator() {
+		p.nextToken()
+	}
+
+	if hasNil(stmt.Values...) {
+		return nil
+	}
+
+	if len(stmt.Targets) != len(stmt.Values) {
+		msg := fmt.Sprintf("assignment mismatch: %d targets but %d values", len(stmt.Targets), len(stmt.Values))
+		p.addError(msg)
+		return nil
+	}
+
+	return stmt
+}
+
+// hasNil reports whether any of expressions is missing because it failed to
+// parse. A node holding one is dropped, so a later String call doesn't run
+// into it.
+func hasNil(expressions ...ast.Expression) bool {
+	for _, expression := range expressions {
+		if expression == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func isAssignable(expression ast.Expression) bool {
+	switch expression.(type) {
+	case *ast.Identifier, *ast.IndexExpression, *ast.DotExpression:
+		return true
+	default:
+		return false
+	}
+}
+
+func (parser *Parser) parseExpression(precedence int) ast.Expression {
+	parser.depth++
+	defer func() { parser.depth-- }()
+
+	if parser.MaxDepth > 0 && parser.depth > parser.MaxDepth {
+		parser.addErrorKind(parser.curToken, TooDeep, "expression nesting too deep")
+		parser.aborted = true
+		return nil
+	}
+
+	prefix := parser.prefixParseFn[parser.curToken.Type]
+	if prefix == nil {
+		parser.noPrefixPerseFnErrror(parser.curToken)
+		return nil
+	}
+
+	// a nil left side means an error was reported, stop before an infix
+	// parse function gets to see it
+	leftExpression := prefix()
+	for leftExpression != nil && !parser.peekTerminator() && precedence < parser.peekPrecedence() {
+		infix := parser.infixParseFn[parser.peekToken.Type]
+		if infix == nil {
+			return leftExpression
+		}
+
+		parser.nextToken()
+		leftExpression = infix(leftExpression)
+
+	}
+
+	return leftExpression
+}
+
+// suggestKeyword returns the keyword tok is likely a typo of, if it is an
+// identifier.
+func suggestKeyword(tok token.Token) (string, bool) {
+	if tok.Type != token.IDENT {
+		return "", false
+	}
+	return token.SuggestKeyword(tok.Literal)
+}
+
+// checkMistypedKeyword reports a statement starting with an identifier that
+// looks like a mistyped keyword and is directly followed by more code on the
+// same line, like `retrun x;`, which would otherwise silently parse as two
+// expressions.
+func (p *Parser) checkMistypedKeyword(expression ast.Expression) {
+	ident, ok := expression.(*ast.Identifier)
+	if !ok || p.peekToken.Line != ident.Token.Line || p.peekTerminator() {
+		return
+	}
+
+	switch p.peekToken.Type {
+	case token.RBRACE, token.RPAREN, token.EOF:
+		return
+	}
+
+	if keyword, ok := suggestKeyword(ident.Token); ok {
+		msg := fmt.Sprintf("unexpected identifier %s, did you mean `%s`?", ident.Value, keyword)
+		p.addErrorKind(ident.Token, UnexpectedToken, msg)
+	}
+}
+
+func (parser *Parser) noPrefixPerseFnErrror(tok token.Token) {
+	msg := fmt.Sprintf("no prefix parse function for '%s' (%s) found", tok.Literal, tok.Type)
+	parser.addErrorKind(tok, NoPrefixParseFn, msg)
+}
+
+func (parser *Parser) parseIdentifier() ast.Expression {
+	return &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+}
+
+// numberSuffixes maps the type suffixes of number literals to whether they
+// belong on a float. The integer suffixes are rejected on floats and `f` is
+// rejected on integers, so `5f` has to be written `5.0f`.
+var numberSuffixes = map[string]bool{"i": false, "u": false, "l": false, "f": true}
+
+// numberSuffix splits the type suffix off the current number literal and
+// checks it fits the literal. The e of an exponent is part of the number.
+func (p *Parser) numberSuffix(isFloat bool) (string, string, bool) {
+	literal := p.curToken.Literal
+	i := strings.IndexFunc(literal, func(r rune) bool {
+		return unicode.IsLetter(r) && r != 'e' && r != 'E' || r == '_'
+	})
+	if i < 0 {
+		return literal, "", true
+	}
+
+	number, suffix := literal[:i], literal[i:]
+	kind := InvalidInteger
+	if isFloat {
+		kind = InvalidFloat
+	}
+
+	floatSuffix, ok := numberSuffixes[suffix]
+	if !ok {
+		p.addErrorKind(p.curToken, kind, fmt.Sprintf("unknown suffix %q on number literal %s", suffix, literal))
+		return "", "", false
+	}
+	if floatSuffix != isFloat {
+		p.addErrorKind(p.curToken, kind, fmt.Sprintf("suffix %q does not fit number literal %s", suffix, literal))
+		return "", "", false
+	}
+
+	return number, suffix, true
+}
+
+// parseIntegerLiteral parses a decimal literal, or an octal one like C when it
+// starts with 0, so `0755` is 493.
+func (parser *Parser) parseIntegerLiteral() ast.Expression {
+	integerLiteral := &ast.IntegerLiteral{Token: parser.curToken}
+
+	literal, suffix, ok := parser.numberSuffix(false)
+	if !ok {
+		return nil
+	}
+	integerLiteral.Suffix = suffix
+
+	if len(literal) > 1 && literal[0] == '0' {
+		if i := strings.IndexAny(literal, "89"); i >= 0 {
+			msg := fmt.Sprintf("invalid digit %q in octal literal %s", literal[i], literal)
+			parser.addErrorKind(parser.curToken, InvalidInteger, msg)
+			return nil
+		}
+	}
+
+	value, err := strconv.ParseInt(literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		msg := fmt.Sprintf("integer literal out of range: %s", parser.curToken.Literal)
+		parser.addErrorKind(parser.curToken, InvalidInteger, msg)
+		return nil
+	} else if err != nil {
+		msg := fmt.Sprintf("could not parse %q as integer", parser.curToken.Literal)
+		parser.addErrorKind(parser.curToken, InvalidInteger, msg)
+		return nil
+	}
+
+	integerLiteral.Value = value
+
+	return integerLiteral
+}
+
+// operatorAliases maps the word operators to their symbolic form.
+var operatorAliases = map[token.TokenType]string{
+	token.AND_KW: "&&",
+	token.OR_KW:  "||",
+	token.NOT_KW: "!",
+}
+
+// curOperator returns the operator of the current token, normalizing word
+// operators like `and` to their symbolic form.
+func (p *Parser) curOperator() string {
+	if operator, ok := operatorAliases[p.curToken.Type]; ok {
+		return operator
+	}
+	return p.curToken.Literal
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	floatLiteral := &ast.FloatLiteral{Token: p.curToken}
+
+	literal, suffix, ok := p.numberSuffix(true)
+	if !ok {
+		return nil
+	}
+	floatLiteral.Suffix = suffix
+
+	value, err := strconv.ParseFloat(literal, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		msg := fmt.Sprintf("float literal out of range: %s", p.curToken.Literal)
+		p.addErrorKind(p.curToken, InvalidFloat, msg)
+		return nil
+	} else if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addErrorKind(p.curToken, InvalidFloat, msg)
+		return nil
+	}
+
+	floatLiteral.Value = value
+
+	return floatLiteral
+}
+
+// parsePrefixExpression parses its operand at PREFIX precedence, so calls,
+// index and dot expressions bind tighter: `-a[0]` is `-(a[0])`.
+func (parser *Parser) parsePrefixExpression() ast.Expression {
+	expression := &ast.PrefixExpression{
+		Token:    parser.curToken,
+		Operator: parser.curOperator(),
+	}
+
+	parser.nextToken()
+
+	expression.Right = parser.parseExpression(PREFIX)
+	if expression.Right == nil {
+		return nil
+	}
+
+	return expression
+}
+
+type (
+	prefixParseFn func() ast.Expression
+	infixParseFn  func(ast.Expression) ast.Expression
+)
+
+func (parser *Parser) registerPrefixFn(tokkenType token.TokenType, fn prefixParseFn) {
+	parser.prefixParseFn[tokkenType] = fn
+}
+
+func (parser *Parser) registerInfixFn(tokkenType token.TokenType, fn infixParseFn) {
+	parser.infixParseFn[tokkenType] = fn
+}
+
+// SetPrecedence sets the binding power of an infix operator token for this
+// parser only.
+func (p *Parser) SetPrecedence(tokenType token.TokenType, precedence int) {
+	p.precedences[tokenType] = precedence
+}
+
+// RegisterInfix registers the parse function of an infix operator token for
+// this parser only. Use SetPrecedence to give the operator a binding power.
+func (p *Parser) RegisterInfix(tokenType token.TokenType, fn infixParseFn) {
+	p.registerInfixFn(tokenType, fn)
+}
+
+// RegisterStatement registers the parse function of a statement starting
+// with tokenType for this parser only. It takes priority over the built-in
+// statements and is called with the statement's first token as the current
+// token.
+func (p *Parser) RegisterStatement(tokenType token.TokenType, fn func() ast.Statement) {
+	p.statementParseFn[tokenType] = fn
+}
+
+// Precedence returns how tightly the infix operator tokenType binds in this
+// parser, or LOWEST if it is not an infix operator.
+func (p *Parser) Precedence(tokenType token.TokenType) int {
+	return p.getPrecedence(tokenType)
+}
+
+var precedenceNames = map[int]string{
+	LOWEST:      "LOWEST",
+	ASSIGN:      "ASSIGN",
+	PIPE:        "PIPE",
+	OR:          "OR",
+	COALESCE:    "COALESCE",
+	AND:         "AND",
+	RANGE:       "RANGE",
+	EQUALS:      "EQUALS",
+	LESSGREATER: "LESSGREATER",
+	SUM:         "SUM",
+	PRODUCT:     "PRODUCT",
+	PREFIX:      "PREFIX",
+	CALL:        "CALL",
+	INDEX:       "INDEX",
+}
+
+// PrecedenceName returns the name of the precedence constant for precedence,
+// e.g. "SUM", or the number itself for a custom precedence.
+func PrecedenceName(precedence int) string {
+	if name, ok := precedenceNames[precedence]; ok {
+		return name
+	}
+	return strconv.Itoa(precedence)
+}
+
+func (parser *Parser) getPrecedence(tokenType token.TokenType) int {
+	precedence, ok := parser.precedences[tokenType]
+
+	if ok {
+		return precedence
+	}
+
+	return LOWEST
+}
+
+func (parser *Parser) peekPrecedence() int {
+	// a '!' is only a postfix non-null assertion directly after its operand
+	if parser.peekTokenIs(token.BANG) && !parser.peekAdjacent() {
+		return LOWEST
+	}
+	return parser.getPrecedence(parser.peekToken.Type)
+}
+
+// peekAdjacent reports whether the peek token directly follows the current
+// one on the same line, without whitespace in between.
+func (parser *Parser) peekAdjacent() bool {
+	cur, peek := parser.curToken, parser.peekToken
+	return cur.Line == peek.Line && cur.Column+len(cur.Literal) == peek.Column
+}
+
+func (parser *Parser) curPrecendence() int {
+	return parser.getPrecedence(parser.curToken.Type)
+}
+
+func (parser *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    parser.curToken,
+		Operator: parser.curOperator(),
+		Left:     left,
+	}
+
+	precedence := parser.curPrecendence()
+	parser.nextToken()
+	expression.Right = parser.parseExpression(precedence)
+	if expression.Right == nil {
+		return nil
+	}
+
+	parser.checkChainedComparison(expression)
+
+	if parser.FoldConstants {
+		if folded := parser.foldConstant(expression); folded != nil {
+			return folded
+		}
+	}
+
+	return expression
+}
+
+// foldConstant returns the integer literal expression evaluates to, or nil
+// if it can't be folded. Literals with a type suffix are left alone.
+func (p *Parser) foldConstant(expression *ast.InfixExpression) ast.Expression {
+	left, ok := expression.Left.(*ast.IntegerLiteral)
+	if !ok || left.Suffix != "" {
+		return nil
+	}
+
+	right, ok := expression.Right.(*ast.IntegerLiteral)
+	if !ok || right.Suffix != "" {
+		return nil
+	}
+
+	var value int64
+	switch expression.Operator {
+	case "+":
+		value = left.Value + right.Value
+	case "-":
+		value = left.Value - right.Value
+	case "*":
+		value = left.Value * right.Value
+	case "/":
+		if right.Value == 0 {
+			p.addErrorAt(expression.Token, fmt.Sprintf("division by zero: %s", expression))
+			return nil
+		}
+		value = left.Value / right.Value
+	default:
+		return nil
+	}
+
+	tok := left.Token
+	tok.Literal = strconv.FormatInt(value, 10)
+
+	return &ast.IntegerLiteral{Token: tok, Value: value}
+}
+
+func (p *Parser) checkChainedComparison(expression *ast.InfixExpression) {
+	left, ok := expression.Left.(*ast.InfixExpression)
+	if !ok || expression.Right == nil {
+		return
+	}
+
+	precedence := p.getPrecedence(expression.Token.Type)
+	if precedence != EQUALS && precedence != LESSGREATER {
+		return
+	}
+
+	if p.getPrecedence(left.Token.Type) != precedence {
+		return
+	}
+
+	msg := fmt.Sprintf("chained comparison %s %s %s %s %s is likely a mistake; use &&",
+		left.Left, left.Operator, left.Right, expression.Operator, expression.Right)
+	if p.StrictComparisons {
+		p.addError(msg)
+	} else {
+		p.addWarning(expression.Token, msg)
+	}
+}
+
+func (parser *Parser) curTokenIs(t token.TokenType) bool {
+	return parser.curToken.Type == t
+}
+
+func (parser *Parser) peekTokenIs(t token.TokenType) bool {
+	return parser.peekToken.Type == t
+}
+
+// peekTerminator reports whether the next token ends a statement.
+func (p *Parser) peekTerminator() bool {
+	return p.isTerminator(p.peekToken)
+}
+
+// isTerminator reports whether tok ends a statement. A newline in newline
+// mode does regardless of Terminators.
+func (p *Parser) isTerminator(tok token.Token) bool {
+	return tok.Type == token.NEWLINE || p.Terminators[tok.Type]
+}
+
+func (p *Parser) skipPeekNewlines() {
+	for p.peekTokenIs(token.NEWLINE) {
+		p.nextToken()
+	}
+}
+
+func (parser *Parser) parseBoolean() ast.Expression {
+	return &ast.Boolean{Token: parser.curToken, Value: parser.curTokenIs(token.TRUE)}
+}
+
+func (parser *Parser) parseGroupedExpression() ast.Expression {
+	if parser.isArrowFunction() {
+		return parser.parseArrowFunction()
+	}
+
+	open := parser.curToken
+	tuple := &ast.TupleLiteral{Token: parser.curToken, Elements: []ast.Expression{}}
+	if parser.peekTokenIs(token.RPAREN) {
+		parser.nextToken()
+		return tuple
+	}
+
+	parser.nextToken()
+
+	errorCount := len(parser.errors)
+	expression := parser.parseExpression(LOWEST)
+
+	if parser.peekTokenIs(token.COMMA) {
+		tuple.Elements = append(tuple.Elements, expression)
+		for parser.peekTokenIs(token.COMMA) {
+			parser.nextToken()
+			parser.nextToken()
+			tuple.Elements = append(tuple.Elements, parser.parseExpression(LOWEST))
+		}
+		expression = tuple
+		if hasNil(tuple.Elements...) {
+			expression = nil
+		}
+	}
+
+	if !parser.expectClosingParen(open, errorCount) {
+		// past the ')' the partial group can still be an operand
+		if parser.curTokenIs(token.RPAREN) {
+			return expression
+		}
+		return nil
+	}
+
+	return expression
+}
+
+// expectClosingParen advances to the ')' closing the group opened by open.
+// A group still open at the end of the statement is reported at open. Any
+// other token is unexpected and the rest of the group is skipped, so it
+// doesn't cause more errors. errorCount is the number of errors before the
+// group's contents were parsed.
+func (p *Parser) expectClosingParen(open token.Token, errorCount int) bool {
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return true
+	}
+
+	// the ')' was already taken as a missing operand, like in `(1 + )`
+	if len(p.errors) > errorCount && p.curTokenIs(token.RPAREN) {
+		return false
+	}
+
+	switch p.peekToken.Type {
+	case token.EOF, token.SEMICOLON, token.NEWLINE, token.RBRACE, token.RBRACKET:
+		p.addErrorKind(open, UnexpectedToken, fmt.Sprintf("unclosed '(' opened at %d:%d", open.Line, open.Column))
+		return false
+	}
+
+	p.peekError(token.RPAREN)
+	for depth := 0; !p.peekTokenIs(token.EOF); {
+		p.nextToken()
+		if p.curTokenIs(token.LPAREN) {
+			depth++
+		} else if p.curTokenIs(token.RPAREN) {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+	}
+	return false
+}
+
+// isArrowFunction scans ahead from the current '(' to check whether it
+// starts an arrow function like `(a, b) => ...`. No tokens are consumed.
+func (p *Parser) isArrowFunction() bool {
+	scanToken := p.scanAhead()
+
+	tok := p.peekToken
+	if tok.Type == token.IDENT {
+		for {
+			tok = scanToken()
+			if tok.Type != token.COMMA {
+				break
+			}
+
+			tok = scanToken()
+			if tok.Type != token.IDENT {
+				return false
+			}
+		}
+	}
+
+	if tok.Type != token.RPAREN {
+		return false
+	}
+
+	return scanToken().Type == token.ARROW
+}
+
+func (p *Parser) parseArrowFunction() ast.Expression {
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+	lit.Token.Type, lit.Token.Literal = token.FUNCTION, "fn"
+
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	lit.Body = p.parseArrowBody()
+
+	return lit
+}
+
+// parseArrowBody parses the body following a '=>'. A single expression is
+// wrapped in a block with an implicit return.
+func (p *Parser) parseArrowBody() *ast.BlockStatement {
+	arrow := p.curToken
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		return p.parseBlockStatement()
+	}
+
+	p.nextToken()
+	returnStmt := &ast.ReturnStatement{Token: p.curToken}
+	returnStmt.Token.Type, returnStmt.Token.Literal = token.RETURN, "return"
+	returnStmt.ReturnValue = p.parseExpression(LOWEST)
+
+	return &ast.BlockStatement{Token: arrow, Statements: []ast.Statement{returnStmt}}
+}
+
+func (p *Parser) parseIfExpression() ast.Expression {
+	expression := &ast.IfExpression{Token: p.curToken}
+
+	condition, consequence, alternative, ok := p.parseConditional()
+	if !ok {
+		return nil
+	}
+
+	expression.Condition = condition
+	expression.Consequence = consequence
+	expression.Alternative = alternative
+
+	return expression
+}
+
+func (p *Parser) parseUnlessExpression() ast.Expression {
+	expression := &ast.UnlessExpression{Token: p.curToken}
+
+	condition, consequence, alternative, ok := p.parseConditional()
+	if !ok {
+		return nil
+	}
+
+	expression.Condition = condition
+	expression.Consequence = consequence
+	expression.Alternative = alternative
+
+	return expression
+}
+
+func (p *Parser) parseWhileExpression() ast.Expression {
+	expression := &ast.WhileExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	if expression.Condition == nil {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseForInExpression() ast.Expression {
+	expression := &ast.ForInExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	expression.Var = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		expression.Index = expression.Var
+		expression.Var = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	if expression.Iterable == nil {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseDoWhileExpression() ast.Expression {
+	expression := &ast.DoWhileExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	if !p.expectPeek(token.WHILE) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) || expression.Condition == nil {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	if !p.expectPeek(token.CATCH) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		expression.Binding = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Handler = p.parseBlockStatement()
+
+	return expression
+}
+
+// parseConditional parses the `(condition) { ... } else { ... }` part shared
+// by if and unless, starting with the keyword as the current token.
+func (p *Parser) parseConditional() (ast.Expression, *ast.BlockStatement, *ast.BlockStatement, bool) {
+	if !p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		condition := p.parseExpression(LOWEST)
+		return p.parseThenBranches(condition)
+	}
+
+	p.nextToken()
+	p.nextToken()
+	condition := p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil, nil, nil, false
+	}
+
+	if p.peekTokenIs(token.THEN) {
+		return p.parseThenBranches(condition)
+	}
+
+	consequence := p.parseBranch()
+
+	var alternative *ast.BlockStatement
+	if p.peekTokenIs(token.ELSE) {
+		p.nextToken()
+		alternative = p.parseBranch()
+	}
+
+	return condition, consequence, alternative, condition != nil
+}
+
+// parseThenBranches parses the `then a else b` following the condition of an
+// if expression like `if x then a else b`. Both branches are single
+// expressions, wrapped in a block each.
+func (p *Parser) parseThenBranches(condition ast.Expression) (ast.Expression, *ast.BlockStatement, *ast.BlockStatement, bool) {
+	if !p.expectPeek(token.THEN) {
+		return nil, nil, nil, false
+	}
+	consequence := p.parseExpressionBranch()
+
+	if !p.expectPeek(token.ELSE) {
+		return nil, nil, nil, false
+	}
+	alternative := p.parseExpressionBranch()
+
+	ok := condition != nil && consequence != nil && alternative != nil
+	return condition, consequence, alternative, ok
+}
+
+// parseExpressionBranch parses the expression following a then or an else
+// into a block holding a single expression statement, or nil if the
+// expression fails to parse.
+func (p *Parser) parseExpressionBranch() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+
+	p.nextToken()
+	statement := &ast.ExpressionStatement{Token: p.curToken}
+	statement.Expression = p.parseExpression(LOWEST)
+	if statement.Expression == nil {
+		return nil
+	}
+	block.Statements = []ast.Statement{statement}
+
+	return block
+}
+
+// parseBranch parses the block following a condition or an else. Without
+// braces a single statement is parsed and wrapped in a block, so
+// `if (done) return result;` needs no braces.
+func (p *Parser) parseBranch() *ast.BlockStatement {
+	p.nextToken()
+	if p.curTokenIs(token.LBRACE) {
+		return p.parseBlockStatement()
+	}
+
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	if statement := p.parseStatement(); statement != nil {
+		block.Statements = append(block.Statements, statement)
+	}
+
+	return block
+}
+
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		statement := p.parseStatement()
+		if statement != nil {
+			block.Statements = append(block.Statements, statement)
+		}
+		p.nextToken()
+	}
+
+	return block
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if p.curTokenIs(token.FUNCTION) && p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		lit.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+
+	if p.peekTokenIs(token.ARROW) {
+		p.nextToken()
+		lit.Body = p.parseArrowBody()
+		return lit
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+
+	return lit
 }
 
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return identifiers
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return identifiers
+	}
+
+	p.nextToken()
+
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	identifiers = append(identifiers, ident)
+	p.checkBuiltinShadow(ident)
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		identifiers = append(identifiers, ident)
+		p.checkBuiltinShadow(ident)
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return identifiers
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	expression := &ast.CallExpression{Token: p.curToken, Function: function}
+	expression.Arguments = []ast.Expression{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return p.parseTrailingBlock(expression)
+	}
+
+	p.nextToken()
+	if !p.parseCallArgument(expression) {
+		return nil
+	}
+
+	for p.peekTokenIs(token.COMMA) {
+		if p.tooManyElements(len(expression.Arguments), "elements in list") {
+			return nil
+		}
+
+		p.nextToken()
+		p.nextToken()
+		if !p.parseCallArgument(expression) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RPAREN) || hasNil(expression.Arguments...) {
+		return nil
+	}
+	for _, argument := range expression.NamedArguments {
+		if argument.Value == nil {
+			return nil
+		}
+	}
+
+	return p.parseTrailingBlock(expression)
+}
+
+// parseTrailingBlock appends a block following the call's ')' on the same
+// line as a function literal argument, so `each(xs) { |x| puts(x) }` is the
+// same as `each(xs, fn(x) { puts(x) })`. As it becomes a positional
+// argument, it can't follow named arguments.
+func (p *Parser) parseTrailingBlock(call *ast.CallExpression) ast.Expression {
+	if p.noTrailingBlock || !p.peekTokenIs(token.LBRACE) || p.peekToken.Line != p.curToken.Line {
+		return call
+	}
+
+	p.nextToken()
+	brace := p.curToken
+
+	lit := &ast.FunctionLiteral{Token: p.curToken, Parameters: []*ast.Identifier{}}
+	lit.Token.Type, lit.Token.Literal = token.FUNCTION, "fn"
+
+	switch {
+	case p.peekTokenIs(token.OR):
+		// `||` is an empty parameter list
+		p.nextToken()
+	case p.peekTokenIs(token.BAR):
+		p.nextToken()
+		lit.Parameters = p.parseBlockParameters()
+		if lit.Parameters == nil {
+			return nil
+		}
+	}
+
+	lit.Body = p.parseBlockStatement()
+	lit.Body.Token = brace
+
+	if len(call.NamedArguments) > 0 {
+		p.addErrorAt(brace, "trailing block follows named argument")
+		return nil
+	}
+
+	call.Arguments = append(call.Arguments, lit)
+
+	return call
+}
+
+// parseBlockParameters parses the parameters of a trailing block like
+// `|a, b|`, starting at the first '|'.
+func (p *Parser) parseBlockParameters() []*ast.Identifier {
+	identifiers := []*ast.Identifier{}
+
+	for !p.peekTokenIs(token.BAR) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		identifiers = append(identifiers, ident)
+		p.checkBuiltinShadow(ident)
+
+		if !p.peekTokenIs(token.BAR) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	return identifiers
+}
+
+// parseCallArgument parses a positional or a named (`name: value`) argument
+// into call. Positional arguments must not follow named ones.
+func (p *Parser) parseCallArgument(call *ast.CallExpression) bool {
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+		argument := &ast.NamedArgument{
+			Token: p.curToken,
+			Name:  &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		}
+
+		p.nextToken()
+		p.nextToken()
+		argument.Value = p.parseExpression(LOWEST)
+
+		call.NamedArguments = append(call.NamedArguments, argument)
+		return true
+	}
+
+	argument := p.parseListElement()
+	if len(call.NamedArguments) > 0 {
+		msg := fmt.Sprintf("positional argument %s follows named argument", argument)
+		p.addError(msg)
+		return false
+	}
+
+	call.Arguments = append(call.Arguments, argument)
+	return true
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseBytesLiteral() ast.Expression {
+	return &ast.BytesLiteral{Token: p.curToken, Value: []byte(p.curToken.Literal)}
+}
+
+func (p *Parser) parseRegexLiteral() ast.Expression {
+	literal := p.curToken.Literal
+	end := strings.LastIndex(literal, "/")
+
+	return &ast.RegexLiteral{Token: p.curToken, Pattern: literal[1:end], Flags: literal[end+1:]}
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		array.Elements = []ast.Expression{}
+		return array
+	}
+
+	p.nextToken()
+	first := p.parseListElement()
+
+	if p.peekTokenIs(token.FOR) {
+		return p.parseListComprehension(array.Token, first)
+	}
+
+	array.Elements = p.parseExpressionList(first, token.RBRACKET)
+	if array.Elements == nil {
+		return nil
+	}
+	return array
+}
+
+// parseListComprehension parses the `for x in xs if cond]` part following the
+// element of `[element for x in xs if cond]`.
+func (p *Parser) parseListComprehension(tok token.Token, element ast.Expression) ast.Expression {
+	comprehension := &ast.ListComprehension{Token: tok, Element: element}
+
+	p.nextToken()
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	comprehension.Var = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
 	}
 
 	p.nextToken()
+	comprehension.Iterable = p.parseExpression(LOWEST)
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	filtered := p.peekTokenIs(token.IF)
+	if filtered {
+		p.nextToken()
+		p.nextToken()
+		comprehension.Filter = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	if hasNil(element, comprehension.Iterable) || filtered && comprehension.Filter == nil {
+		return nil
+	}
+
+	return comprehension
+}
+
+// parseExpressionList parses the rest of a list whose first element has
+// already been parsed.
+func (p *Parser) parseExpressionList(first ast.Expression, end token.TokenType) []ast.Expression {
+	list := []ast.Expression{first}
 
 	for p.peekTokenIs(token.COMMA) {
+		if p.tooManyElements(len(list), "elements in list") {
+			return nil
+		}
+
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		list = append(list, p.parseListElement())
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) || hasNil(list...) {
 		return nil
 	}
 
-	return identifiers
+	return list
 }
 
-func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
-	expression := &ast.CallExpression{Token: p.curToken, Function: function}
-	expression.Arguments = p.parseExpressionList(token.RPAREN)
-	return expression
-}
+// tooManyElements reports an error and aborts parsing when count has
+// reached MaxElements.
+func (p *Parser) tooManyElements(count int, what string) bool {
+	if p.MaxElements <= 0 || count < p.MaxElements {
+		return false
+	}
 
-func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	msg := fmt.Sprintf("too many %s, the limit is %d", what, p.MaxElements)
+	p.addErrorKind(p.peekToken, TooManyElements, msg)
+	p.aborted = true
+
+	return true
 }
 
-func (p *Parser) parseArrayLiteral() ast.Expression {
-	array := &ast.ArrayLiteral{Token: p.curToken}
-	array.Elements = p.parseExpressionList(token.RBRACKET)
-	return array
+func (p *Parser) parseListElement() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadElement{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	if spread.Value == nil {
+		return nil
+	}
+
+	return spread
 }
 
-func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
-	list := []ast.Expression{}
-	if p.peekTokenIs(end) {
-		p.nextToken()
-		return list
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(p.curToken, left, nil)
 	}
 
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	exp.Index = p.parseExpression(LOWEST)
+	exp.FromEnd = isNegativeIntegerLiteral(exp.Index)
 
-	for p.peekTokenIs(token.COMMA) {
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(exp.Token, left, exp.Index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) || exp.Index == nil {
+		return nil
+	}
+
+	return exp
+}
+
+// parseSliceExpression parses the rest of `left[start:stop:step]` from the
+// ':' after start on. bracket is the '[' token.
+func (p *Parser) parseSliceExpression(bracket token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: bracket, Left: left, Start: start}
+	p.nextToken()
+
+	if !p.peekTokenIs(token.COLON) && !p.peekTokenIs(token.RBRACKET) {
 		p.nextToken()
+		exp.Stop = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		if !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			exp.Step = p.parseExpression(LOWEST)
+
+			if step, ok := exp.Step.(*ast.IntegerLiteral); ok && step.Value == 0 {
+				p.addErrorAt(step.Token, "slice step cannot be zero")
+			}
+		}
 	}
 
-	if !p.expectPeek(end) {
+	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
 
-	return list
+	return exp
 }
 
-func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+func isNegativeIntegerLiteral(expression ast.Expression) bool {
+	prefix, ok := expression.(*ast.PrefixExpression)
+	if !ok || prefix.Operator != "-" {
+		return false
+	}
+
+	_, ok = prefix.Right.(*ast.IntegerLiteral)
+	return ok
+}
+
+func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
+	exp := &ast.DotExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+func (p *Parser) parseTypeofExpression() ast.Expression {
+	expression := &ast.TypeofExpression{Token: p.curToken}
 
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	expression.Value = p.parseExpression(PREFIX)
+	if expression.Value == nil {
+		return nil
+	}
 
-	if !p.expectPeek(token.RBRACKET) {
+	return expression
+}
+
+// parseTypeCheckExpression parses `x is Int`. The right side must name a
+// type.
+func (p *Parser) parseTypeCheckExpression(left ast.Expression) ast.Expression {
+	expression := &ast.TypeCheckExpression{Token: p.curToken, Value: left}
+
+	if !p.peekTokenIs(token.IDENT) {
+		p.addErrorAt(p.peekToken, fmt.Sprintf("expected a type name after is, got %s", p.peekToken.Literal))
+		return nil
+	}
+	p.nextToken()
+	expression.TypeName = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return expression
+}
+
+// parseNonNullAssertion parses a '!' directly following an expression as a
+// postfix non-null assertion. A '!' starting an expression remains the
+// logical not, so `b\n!c` and `a ! b` are still two expressions.
+func (p *Parser) parseNonNullAssertion(left ast.Expression) ast.Expression {
+	return &ast.NonNullAssertion{Token: p.curToken, Left: left}
+}
+
+func (p *Parser) parseOptionalIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.OptionalIndexExpression{Token: p.curToken, Left: left}
+
+	if p.peekTokenIs(token.LBRACKET) {
+		p.nextToken()
+		p.nextToken()
+		exp.Computed = true
+		exp.Index = p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.RBRACKET) || exp.Index == nil {
+			return nil
+		}
+
+		return exp
+	}
+
+	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
+	exp.Index = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
 	return exp
 }
 
+// parseBraceExpression parses either a hash literal or a block expression,
+// depending on what follows the '{'. `{}`, `{x}` and anything with a
+// top-level ':' or ',' is a hash, so `{x}` is the shorthand for `{x: x}`.
+// Everything else is a block; to yield a lone name from a block, end it with
+// a semicolon: `{ x; }`.
+func (p *Parser) parseBraceExpression() ast.Expression {
+	if p.isHashLiteral() {
+		return p.parseHashLiteral()
+	}
+
+	expression := &ast.BlockExpression{Token: p.curToken}
+	expression.Block = p.parseBlockStatement()
+
+	return expression
+}
+
+// isHashLiteral scans ahead from the current '{' without consuming tokens.
+// An empty `{}`, a lone identifier like `{x}` or a top-level ':' or ','
+// means a hash literal, while a top-level ';', a statement keyword or the
+// closing '}' means a block.
+func (p *Parser) isHashLiteral() bool {
+	if p.peekTokenIs(token.RBRACE) {
+		return true
+	}
+
+	scanToken := p.scanAhead()
+	depth := 0
+	for i, tok := 0, p.peekToken; ; i, tok = i+1, scanToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if depth == 0 {
+				return tok.Type == token.RBRACE && i == 1 && p.peekTokenIs(token.IDENT)
+			}
+			depth--
+		case token.COLON, token.COMMA:
+			if depth == 0 {
+				return true
+			}
+		case token.SEMICOLON, token.NEWLINE, token.LET, token.RETURN:
+			if depth == 0 {
+				return false
+			}
+		case token.EOF:
+			return true
+		}
+	}
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Pairs = []ast.HashPair{}
+	seenKeys := make(map[string]bool)
 
 	for !p.peekTokenIs(token.RBRACE) {
+		if p.tooManyElements(len(hash.Pairs), "pairs in hash literal") {
+			return nil
+		}
+
 		p.nextToken()
-		key := p.parseExpression(LOWEST)
+		if p.curTokenIs(token.IDENT) && (p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.RBRACE)) {
+			p.parseHashShorthand(hash, seenKeys)
+			continue
+		}
+
+		key := p.parseHashKey()
+		p.checkDuplicateKey(key, seenKeys)
 
 		if !p.expectPeek(token.COLON) {
 			return nil
@@ -472,8 +2640,9 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
 
+		p.skipPeekNewlines()
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
 		}
@@ -483,5 +2652,232 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		return nil
 	}
 
+	for _, pair := range hash.Pairs {
+		if hasNil(pair.Key, pair.Value) {
+			return nil
+		}
+	}
+
 	return hash
 }
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+	expression.Arms = []ast.MatchArm{}
+
+	p.nextToken()
+	noTrailingBlock := p.noTrailingBlock
+	p.noTrailingBlock = true
+	expression.Subject = p.parseExpression(LOWEST)
+	p.noTrailingBlock = noTrailingBlock
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := ast.MatchArm{Patterns: []ast.Expression{p.parseMatchPattern()}}
+		for p.peekTokenIs(token.BAR) {
+			p.nextToken()
+			p.nextToken()
+			arm.Patterns = append(arm.Patterns, p.parseMatchPattern())
+		}
+
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+
+		p.nextToken()
+		arm.Result = p.parseExpression(LOWEST)
+		expression.Arms = append(expression.Arms, arm)
+
+		p.skipPeekNewlines()
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+		p.skipPeekNewlines()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	if len(expression.Arms) == 0 {
+		p.addError("match expression has no arms")
+		return nil
+	}
+
+	if expression.Subject == nil {
+		return nil
+	}
+	for _, arm := range expression.Arms {
+		if hasNil(arm.Result) || hasNil(arm.Patterns...) {
+			return nil
+		}
+	}
+
+	return expression
+}
+
+// parseMatchPattern parses a match arm pattern. A leading '[' or '{' starts
+// a destructuring pattern like in let, anything else is an expression.
+func (p *Parser) parseMatchPattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	default:
+		return p.parseExpression(LOWEST)
+	}
+}
+
+func (p *Parser) parseStructLiteral() ast.Expression {
+	literal := &ast.StructLiteral{Token: p.curToken}
+	literal.Fields = []ast.StructField{}
+	seenFields := make(map[string]bool)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if p.tooManyElements(len(literal.Fields), "fields in struct literal") {
+			return nil
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seenFields[name.Value] {
+			msg := fmt.Sprintf("duplicate field %s in struct literal", name.Value)
+			p.addError(msg)
+		}
+		seenFields[name.Value] = true
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		literal.Fields = append(literal.Fields, ast.StructField{Name: name, Value: value})
+
+		p.skipPeekNewlines()
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	for _, field := range literal.Fields {
+		if field.Value == nil {
+			return nil
+		}
+	}
+
+	return literal
+}
+
+// constantHashKey returns a printable representation of a literal hash key
+// that compares by value. Keys that are not literals report false.
+func constantHashKey(key ast.Expression) (string, bool) {
+	switch key := key.(type) {
+	case *ast.StringLiteral:
+		return strconv.Quote(key.Value), true
+	case *ast.IntegerLiteral:
+		return strconv.FormatInt(key.Value, 10), true
+	case *ast.Boolean:
+		return strconv.FormatBool(key.Value), true
+	default:
+		return "", false
+	}
+}
+
+// parsePipeExpression rewrites `x |> f(a)` into the call `f(x, a)` and
+// `x |> f` into `f(x)`.
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	pipeToken := p.curToken
+
+	precedence := p.curPrecendence()
+	p.nextToken()
+	right := p.parseExpression(precedence)
+
+	switch right := right.(type) {
+	case *ast.CallExpression:
+		right.Arguments = append([]ast.Expression{left}, right.Arguments...)
+		return right
+	case *ast.Identifier:
+		return &ast.CallExpression{Token: pipeToken, Function: right, Arguments: []ast.Expression{left}}
+	case nil:
+		return nil
+	default:
+		msg := fmt.Sprintf("right side of %s must be a call or identifier, got %s", pipeToken.Literal, right)
+		p.addError(msg)
+		return nil
+	}
+}
+
+func (p *Parser) parseRangeExpression(left ast.Expression) ast.Expression {
+	expression := &ast.RangeExpression{
+		Token:     p.curToken,
+		Start:     left,
+		Exclusive: p.curTokenIs(token.DOTDOTLT),
+	}
+
+	precedence := p.curPrecendence()
+	p.nextToken()
+	expression.End = p.parseExpression(precedence)
+	if expression.End == nil {
+		return nil
+	}
+
+	return expression
+}
+
+// parseHashShorthand adds the pair for a bare identifier followed by ',' or
+// '}', so `{x, y: 2}` is the same as `{"x": x, "y": 2}`.
+func (p *Parser) parseHashShorthand(hash *ast.HashLiteral, seenKeys map[string]bool) {
+	key := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	key.Token.Type = token.STRING
+	p.checkDuplicateKey(key, seenKeys)
+
+	value := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+	}
+}
+
+func (p *Parser) checkDuplicateKey(key ast.Expression, seenKeys map[string]bool) {
+	if constant, ok := constantHashKey(key); ok {
+		if seenKeys[constant] {
+			msg := fmt.Sprintf("duplicate key %s in hash literal", constant)
+			p.addError(msg)
+		}
+		seenKeys[constant] = true
+	}
+}
+
+// parseHashKey treats a bare identifier followed by ':' as a string key, so
+// `{name: 1}` is the same as `{"name": 1}`. A bare identifier followed by ','
+// or '}' is a shorthand pair, see parseHashShorthand. Any other key is parsed
+// as an expression; wrap an identifier in parens to use its value as the key.
+func (p *Parser) parseHashKey() ast.Expression {
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+		key := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+		key.Token.Type = token.STRING
+		return key
+	}
+
+	return p.parseExpression(LOWEST)
+}
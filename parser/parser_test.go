@@ -1,9 +1,16 @@
 package parser
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"monkey/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -52,7 +59,7 @@ func TestParseErrors(testing *testing.T) {
 	p.ParseProgram()
 
 	for _, err := range p.errors {
-		testing.Logf(err)
+		testing.Logf(err.Message)
 	}
 
 	if len(p.errors) != 4 {
@@ -60,6 +67,236 @@ func TestParseErrors(testing *testing.T) {
 	}
 }
 
+func TestParseProgramE(t *testing.T) {
+	p := New(lexer.New("let x = 5;"))
+	program, err := p.ParseProgramE()
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+}
+
+func TestParseProgramEErrors(t *testing.T) {
+	input := `
+	let x 5;
+	let = 10;
+	`
+	p := New(lexer.New(input))
+	_, err := p.ParseProgramE()
+	if err == nil {
+		t.Fatalf("expected an error, got nil")
+	}
+
+	parseError, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("err is not *ParseError. got=%T", err)
+	}
+
+	if len(parseError.Errors) != len(p.Errors()) {
+		t.Fatalf("wrong number of errors. got=%d, expected=%d", len(parseError.Errors), len(p.Errors()))
+	}
+
+	first := parseError.Errors[0]
+	expected := "expected next token to be ASSIGN, got '5' (INT) instead"
+	if first.Message != expected || first.Kind != UnexpectedToken || first.Line != 2 || first.Column != 8 {
+		t.Errorf("parseError.Errors[0] wrong. expected=%q at 2:8, got=%+v", expected, first)
+	}
+
+	if err.Error() != strings.Join(p.Errors(), "\n") {
+		t.Errorf("err.Error() wrong. got=%q", err.Error())
+	}
+}
+
+func TestParseProgramStrict(t *testing.T) {
+	p := New(lexer.New("let x = 5;\nlet add = fn(a, b) { a + b };\nadd(x, 1);"))
+	program, err := p.ParseProgramStrict()
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
+	}
+}
+
+func TestParseProgramStrictStopsAtFirstError(t *testing.T) {
+	input := `let x = 5;
+let y 6;
+let = 10;
+puts(x);`
+
+	p := New(lexer.New(input))
+	program, err := p.ParseProgramStrict()
+	if err == nil {
+		t.Fatalf("expected an error, got nil")
+	}
+
+	expected := "2:7: expected next token to be ASSIGN, got '6' (INT) instead"
+	if err.Error() != expected {
+		t.Errorf("err.Error() wrong. expected=%q, got=%q", expected, err.Error())
+	}
+
+	var parseErr Error
+	if !errors.As(err, &parseErr) || parseErr.Kind != UnexpectedToken || parseErr.Line != 2 {
+		t.Errorf("err does not wrap the Error. got=%#v", err)
+	}
+
+	if len(p.Errors()) != 1 {
+		t.Errorf("parsing went on after the first bad statement. errors=%q", p.Errors())
+	}
+
+	if len(program.Statements) != 1 || program.Statements[0].String() != "let x = 5;" {
+		t.Errorf("program wrong. got=%q", program.String())
+	}
+}
+
+func TestDetailedErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedKind   ErrorKind
+		expectedLine   int
+		expectedColumn int
+	}{
+		{"let x 5;", UnexpectedToken, 1, 7},
+		{"let x = 1;\n  ) + 1", NoPrefixParseFn, 2, 3},
+		{"1 + 99999999999999999999", InvalidInteger, 1, 5},
+		{`let s = "\q";`, InvalidString, 1, 9},
+		{`{ f("\q") }`, InvalidString, 1, 5},
+		{"let x = 1;\n  (1 + 2", UnexpectedToken, 2, 3},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.DetailedErrors()
+		if len(errors) == 0 {
+			t.Fatalf("%q - expected parser errors", tt.input)
+		}
+
+		err := errors[0]
+		if err.Kind != tt.expectedKind {
+			t.Errorf("%q - kind wrong. expected=%d, got=%d", tt.input, tt.expectedKind, err.Kind)
+		}
+
+		if err.Line != tt.expectedLine || err.Column != tt.expectedColumn {
+			t.Errorf("%q - position wrong. expected=%d:%d, got=%d:%d",
+				tt.input, tt.expectedLine, tt.expectedColumn, err.Line, err.Column)
+		}
+
+		if err.Error() != p.Errors()[0] {
+			t.Errorf("%q - Errors()[0] does not match the message. expected=%q, got=%q", tt.input, err.Message, p.Errors()[0])
+		}
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "valid.monkey")
+	if err := os.WriteFile(filename, []byte("let x = 5;\nx + 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	program, err := ParseFile(filename)
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+}
+
+func TestParseFileErrors(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "invalid.monkey")
+	if err := os.WriteFile(filename, []byte("let x = 5;\nlet y 6;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ParseFile(filename)
+	if err == nil {
+		t.Fatalf("expected an error, got nil")
+	}
+
+	expected := filename + ":2:7: expected next token to be ASSIGN, got '6' (INT) instead"
+	if err.Error() != expected {
+		t.Errorf("err.Error() wrong. expected=%q, got=%q", expected, err.Error())
+	}
+
+	parseError, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("err is not *ParseError. got=%T", err)
+	}
+
+	if parseError.Filename != filename || len(parseError.Errors) != 1 || parseError.Errors[0].Kind != UnexpectedToken {
+		t.Errorf("parseError wrong. got=%+v", parseError)
+	}
+}
+
+func TestParseFileMissing(t *testing.T) {
+	_, err := ParseFile(filepath.Join(t.TempDir(), "missing.monkey"))
+	if !os.IsNotExist(err) {
+		t.Errorf("expected a not-exist error, got %v", err)
+	}
+}
+
+func TestParseErrorsAreCapped(t *testing.T) {
+	input := strings.Repeat(") ] } let = ; ", 500)
+
+	l := lexer.New(input)
+	p := New(l)
+	p.MaxErrors = 10
+	p.ParseProgram()
+
+	if len(p.errors) != p.MaxErrors {
+		t.Fatalf("expected p.errors to contain %d errors, got=%d", p.MaxErrors, len(p.errors))
+	}
+
+	last := p.errors[len(p.errors)-1]
+	if last.Message != "too many errors, aborting" {
+		t.Errorf("last error wrong. got=%q", last.Message)
+	}
+
+	if last.Kind != TooManyErrors {
+		t.Errorf("last error kind wrong. expected=%d, got=%d", TooManyErrors, last.Kind)
+	}
+}
+
+func TestParseErrorsDefaultCap(t *testing.T) {
+	input := strings.Repeat("]", 10000)
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.errors) > DefaultMaxErrors {
+		t.Fatalf("expected at most %d errors, got=%d", DefaultMaxErrors, len(p.errors))
+	}
+}
+
+func TestParseErrorsCappedAtOne(t *testing.T) {
+	p := New(lexer.New("let x 5; let y 6;"))
+	p.MaxErrors = 1
+	p.ParseProgram()
+
+	expected := []string{
+		"too many errors, aborting",
+	}
+	if strings.Join(p.Errors(), "\n") != strings.Join(expected, "\n") {
+		t.Errorf("errors wrong. expected=%q, got=%q", expected, p.Errors())
+	}
+
+	p = New(lexer.New("let x 5;"))
+	p.MaxErrors = 1
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 || p.DetailedErrors()[0].Kind == TooManyErrors {
+		t.Errorf("a single error was replaced. got=%q", p.Errors())
+	}
+}
+
 func checkParserErrors(testing *testing.T, parser *Parser) {
 	errors := parser.Errors()
 	if len(errors) == 0 {
@@ -403,6 +640,51 @@ func TestOperatorPrecedenceParsing(testing *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			`"a" <> "b" <> "c"`,
+			"((a <> b) <> c)",
+		},
+		{
+			"a <> b * c",
+			"(a <> (b * c))",
+		},
+		{
+			"a + b <> c - d",
+			"(((a + b) <> c) - d)",
+		},
+		{
+			"[1] <> xs == ys",
+			"(([1] <> xs) == ys)",
+		},
+		// a prefix operator applies to the result of an index expression
+		{
+			"-a[0]",
+			"(-(a[0]))",
+		},
+		{
+			"!arr[i]",
+			"(!(arr[i]))",
+		},
+		{
+			"-[1, 2, 3][1]",
+			"(-([1, 2, 3][1]))",
+		},
+		{
+			"![true][0]",
+			"(!([true][0]))",
+		},
+		{
+			`-{"a": 1}["a"]`,
+			"(-({a:1}[a]))",
+		},
+		{
+			"-a[0] * b[1]",
+			"((-(a[0])) * (b[1]))",
+		},
+		{
+			"-f(x)[0]",
+			"(-(f(x)[0]))",
+		},
 	}
 
 	for _, test := range tests {
@@ -583,6 +865,85 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestIfThenExpression(t *testing.T) {
+	tests := []struct {
+		input       string
+		condition   string
+		consequence string
+		alternative string
+	}{
+		{"if x then 1 else 2", "x", "1", "2"},
+		{"let y = if a > b then a else b;", "(a > b)", "a", "b"},
+		{"if (ready) then go() else wait()", "ready", "go()", "wait()"},
+		{"unless done then more else 0", "done", "more", "0"},
+		{"if a then 1 else if b then 2 else 3", "a", "1", "ifb 2else 3"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: program.Statements does not contain 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+
+		var expression ast.Expression
+		switch stmt := program.Statements[0].(type) {
+		case *ast.ExpressionStatement:
+			expression = stmt.Expression
+		case *ast.LetStatement:
+			expression = stmt.Value
+		}
+
+		var condition ast.Expression
+		var consequence, alternative *ast.BlockStatement
+		switch exp := expression.(type) {
+		case *ast.IfExpression:
+			condition, consequence, alternative = exp.Condition, exp.Consequence, exp.Alternative
+		case *ast.UnlessExpression:
+			condition, consequence, alternative = exp.Condition, exp.Consequence, exp.Alternative
+		default:
+			t.Fatalf("%q: exp is not a conditional. got=%T", tt.input, expression)
+		}
+
+		if condition.String() != tt.condition {
+			t.Errorf("%q: condition wrong. expected=%q, got=%q", tt.input, tt.condition, condition.String())
+		}
+		for _, branch := range []struct {
+			block    *ast.BlockStatement
+			expected string
+		}{{consequence, tt.consequence}, {alternative, tt.alternative}} {
+			if len(branch.block.Statements) != 1 {
+				t.Fatalf("%q: branch does not contain 1 statement. got=%d", tt.input, len(branch.block.Statements))
+			}
+			if branch.block.String() != branch.expected {
+				t.Errorf("%q: branch wrong. expected=%q, got=%q", tt.input, branch.expected, branch.block.String())
+			}
+		}
+	}
+}
+
+func TestIfThenExpressionRequiresElse(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"if x then 1", "expected next token to be ELSE, got '' (EOF) instead"},
+		{"if x { 1 }", "expected next token to be THEN, got '{' (LBRACE) instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expectedError {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expectedError, p.Errors())
+		}
+	}
+}
+
 func TestIfElseExpression(t *testing.T) {
 	input := `if (x < y) { x } else { y }`
 
@@ -820,96 +1181,443 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
-func TestParsingArrayLiterals(t *testing.T) {
-	input := "[1, 2 * 2, 3 + 3]"
+func TestStringLiteralEscapes(t *testing.T) {
+	input := `"\x41\U0001F600";`
 
-	l := lexer.New(input)
-	p := New(l)
+	p := New(lexer.New(input))
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
 	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
 	if !ok {
-		t.Fatalf("exp not *ast.ArrayLiteral. got=%T", stmt.Expression)
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
 	}
 
-	if len(array.Elements) != 3 {
-		t.Errorf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	if literal.Value != "A😀" {
+		t.Errorf("literal.Value  not %q. got=%q", "A😀", literal.Value)
 	}
-
-	testIntegerLiteral(t, array.Elements[0], 1)
-	testInfixExpression(t, array.Elements[1], 2, "*", 2)
-	testInfixExpression(t, array.Elements[2], 3, "+", 3)
 }
 
-func TestParsingEmptyArrayLiteral(t *testing.T) {
-	input := "[]"
+func TestStringLiteralEscapeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = "\x1";`, `\x escape needs 2 hex digits, got \x1`},
+		{`puts("\U00110000")`, `invalid code point \U00110000 in string literal`},
+	}
 
-	l := lexer.New(input)
-	p := New(l)
-	program := p.ParseProgram()
-	checkParserErrors(t, p)
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
 
-	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	array, ok := stmt.Expression.(*ast.ArrayLiteral)
-	if !ok {
-		t.Fatalf("exp not *ast.ArrayLiteral. got=%T", stmt.Expression)
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%s - expected error %q, got=%q", tt.input, tt.expected, errors)
+		}
 	}
+}
 
-	if len(array.Elements) != 0 {
-		t.Errorf("len(array.Elements) not 3. got=%d", len(array.Elements))
+func TestBytesLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []byte
+		string   string
+	}{
+		{`b"AB"`, []byte{65, 66}, `b"AB"`},
+		{`b"\x00"`, []byte{0}, `b"\x00"`},
+		{`b"\xca\xfe\t\""`, []byte{0xca, 0xfe, '\t', '"'}, `b"\xca\xfe\t\""`},
 	}
-}
 
-func TestParsingIndexExpression(t *testing.T) {
-	input := "myArray[1 + 1]"
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
 
-	l := lexer.New(input)
-	p := New(l)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.BytesLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.BytesLiteral. got=%T", stmt.Expression)
+		}
+
+		if !bytes.Equal(literal.Value, tt.expected) {
+			t.Errorf("literal.Value wrong. expected=%v, got=%v", tt.expected, literal.Value)
+		}
+		if literal.String() != tt.string {
+			t.Errorf("literal.String() wrong. expected=%q, got=%q", tt.string, literal.String())
+		}
+	}
+}
+
+func TestBytesPrefixOnNonString(t *testing.T) {
+	p := New(lexer.New(`b + 1; b[0]; b "x"`))
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
-	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
-	if !ok {
-		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	expected := `(b + 1)(b[0])bx`
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestBytesLiteralEndsExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = b\"ab\"\n-1", []string{`let x = b"ab";`, "(-1)"}},
+		{`b"ab" / 2`, []string{`(b"ab" / 2)`}},
 	}
 
-	if !testIdentifier(t, indexExp.Left, "myArray") {
-		return
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, lexer.WithNewlines()))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != len(tt.expected) {
+			t.Fatalf("%q: wrong number of statements. expected=%d, got=%d: %q",
+				tt.input, len(tt.expected), len(program.Statements), program.String())
+		}
+		for i, stmt := range program.Statements {
+			if stmt.String() != tt.expected[i] {
+				t.Errorf("%q: statement %d wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], stmt.String())
+			}
+		}
 	}
+}
 
-	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
-		return
+func TestBytesLiteralErrors(t *testing.T) {
+	p := New(lexer.New(`let data = b"\u00e9";`))
+	p.ParseProgram()
+
+	errors := p.DetailedErrors()
+	if len(errors) != 1 || errors[0].Kind != InvalidString || errors[0].Message != `invalid escape sequence \u in bytes literal` {
+		t.Errorf("errors wrong. got=%+v", errors)
 	}
 }
 
-func TestParsingHashLiteralsStringKeys(t *testing.T) {
-	input := `{"one": 1, "two": 2, "three": 3}`
+func TestHeredocStringLiteral(t *testing.T) {
+	input := "let s = <<~END;\n  hello\n  world\n  END\nlen(s);"
 
-	l := lexer.New(input)
-	p := New(l)
+	p := New(lexer.New(input))
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
-	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	hash, ok := stmt.Expression.(*ast.HashLiteral)
-	if !ok {
-		t.Fatalf("exp is not *ast.HashLiteral. got=%T", stmt.Expression)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
 	}
 
-	if len(hash.Pairs) != 3 {
-		t.Errorf("hash.Pairs has wrong length. got=%d, expected=3", len(hash.Pairs))
+	stmt := program.Statements[0].(*ast.LetStatement)
+	literal, ok := stmt.Value.(*ast.StringLiteral)
+	if !ok || literal.Value != "hello\nworld\n" {
+		t.Errorf("stmt.Value wrong. got=%#v", stmt.Value)
 	}
 
-	expected := map[string]int64{
-		"one":   1,
-		"two":   2,
+	p = New(lexer.New("let s = <<END\nopen"))
+	p.ParseProgram()
+
+	expected := "unterminated heredoc, missing terminator END"
+	if len(p.Errors()) != 1 || p.Errors()[0] != expected {
+		t.Errorf("expected error %q, got=%q", expected, p.Errors())
+	}
+}
+
+func TestInterfaceDeclarations(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedName    string
+		expectedMethods []string
+		expectedString  string
+	}{
+		{
+			"interface Shape { fn area(): Float; fn name(): String; }",
+			"Shape",
+			[]string{"fn area(): Float", "fn name(): String"},
+			"interface Shape { fn area(): Float; fn name(): String; }",
+		},
+		{
+			"interface Empty {}",
+			"Empty",
+			[]string{},
+			"interface Empty {}",
+		},
+		{
+			"interface Scalable {\n  fn scale(by: Float, origin): Shape\n  fn reset()\n}",
+			"Scalable",
+			[]string{"fn scale(by: Float, origin): Shape", "fn reset()"},
+			"interface Scalable { fn scale(by: Float, origin): Shape; fn reset(); }",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: program.Statements does not contain 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.InterfaceDeclaration)
+		if !ok {
+			t.Fatalf("%q: statement is not *ast.InterfaceDeclaration. got=%T", tt.input, program.Statements[0])
+		}
+
+		if stmt.Name.Value != tt.expectedName {
+			t.Errorf("%q: stmt.Name wrong. expected=%q, got=%q", tt.input, tt.expectedName, stmt.Name.Value)
+		}
+
+		if len(stmt.Methods) != len(tt.expectedMethods) {
+			t.Fatalf("%q: wrong number of methods. expected=%d, got=%d", tt.input, len(tt.expectedMethods), len(stmt.Methods))
+		}
+		for i, method := range stmt.Methods {
+			if method.String() != tt.expectedMethods[i] {
+				t.Errorf("%q: methods[%d] wrong. expected=%q, got=%q", tt.input, i, tt.expectedMethods[i], method.String())
+			}
+		}
+
+		if stmt.String() != tt.expectedString {
+			t.Errorf("%q: stmt.String() wrong. expected=%q, got=%q", tt.input, tt.expectedString, stmt.String())
+		}
+	}
+}
+
+func TestInterfaceMethodWithBody(t *testing.T) {
+	l := lexer.New("interface Shape { fn area(): Float { 1.0 } }")
+	p := New(l)
+	program := p.ParseProgram()
+
+	expected := "interface method area must not have a body"
+	if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+		t.Errorf("expected error %q, got=%q", expected, p.Errors())
+	}
+
+	for _, stmt := range program.Statements {
+		if _, ok := stmt.(*ast.InterfaceDeclaration); ok {
+			t.Errorf("unexpected *ast.InterfaceDeclaration %q", stmt.String())
+		}
+	}
+}
+
+func TestPrintStatements(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedArgs []string
+	}{
+		{`print "hi";`, []string{"hi"}},
+		{"print a, b;", []string{"a", "b"}},
+		{"print a + 1, f(b)", []string{"(a + 1)", "f(b)"}},
+		{"print;", []string{}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: program.Statements does not contain 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.PrintStatement)
+		if !ok {
+			t.Fatalf("%q: statement is not *ast.PrintStatement. got=%T", tt.input, program.Statements[0])
+		}
+
+		if len(stmt.Args) != len(tt.expectedArgs) {
+			t.Fatalf("%q: wrong number of args. expected=%d, got=%d", tt.input, len(tt.expectedArgs), len(stmt.Args))
+		}
+		for i, arg := range stmt.Args {
+			if arg.String() != tt.expectedArgs[i] {
+				t.Errorf("%q: args[%d] wrong. expected=%q, got=%q", tt.input, i, tt.expectedArgs[i], arg.String())
+			}
+		}
+	}
+}
+
+func TestPrintCallExpression(t *testing.T) {
+	l := lexer.New(`print("hi", x); let p = print;`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.CallExpression. got=%T", stmt.Expression)
+	}
+	testIdentifier(t, call.Function, "print")
+
+	if len(call.Arguments) != 2 {
+		t.Fatalf("wrong number of arguments. got=%d", len(call.Arguments))
+	}
+
+	let := program.Statements[1].(*ast.LetStatement)
+	testIdentifier(t, let.Value, "print")
+}
+
+func TestGuardedStatements(t *testing.T) {
+	tests := []struct {
+		input     string
+		statement string
+		condition string
+		negate    bool
+	}{
+		{"return x if a > b;", "return x;", "(a > b)", false},
+		{"puts(x) unless silent;", "puts(x)", "silent", true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: program.Statements does not contain 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+
+		guarded, ok := program.Statements[0].(*ast.GuardedStatement)
+		if !ok {
+			t.Fatalf("%q: statement is not *ast.GuardedStatement. got=%T", tt.input, program.Statements[0])
+		}
+
+		if guarded.Statement.String() != tt.statement {
+			t.Errorf("%q: guarded.Statement wrong. expected=%q, got=%q", tt.input, tt.statement, guarded.Statement.String())
+		}
+		if guarded.Condition.String() != tt.condition {
+			t.Errorf("%q: guarded.Condition wrong. expected=%q, got=%q", tt.input, tt.condition, guarded.Condition.String())
+		}
+		if guarded.Negate != tt.negate {
+			t.Errorf("%q: guarded.Negate wrong. expected=%t, got=%t", tt.input, tt.negate, guarded.Negate)
+		}
+	}
+}
+
+func TestUnguardedStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"return x; if (a) { b }", "return x;ifa b"},
+		{"puts(x)\nunless (quiet) { y }", "puts(x)unlessquiet y"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		for _, stmt := range program.Statements {
+			if _, ok := stmt.(*ast.GuardedStatement); ok {
+				t.Errorf("%q: unexpected *ast.GuardedStatement %q", tt.input, stmt.String())
+			}
+		}
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Errorf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingEmptyArrayLiteral(t *testing.T) {
+	input := "[]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+}
+
+func TestParsingIndexExpression(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingHashLiteralsStringKeys(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not *ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Errorf("hash.Pairs has wrong length. got=%d, expected=3", len(hash.Pairs))
+	}
+
+	expected := map[string]int64{
+		"one":   1,
+		"two":   2,
 		"three": 3,
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
@@ -945,7 +1653,8 @@ func TestParsingHashLiteralsIntegerKeys(t *testing.T) {
 		3: "three",
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.IntegerLiteral)
 		if !ok {
 			t.Errorf("key is not ast.IntgegerLiteral. got=%T", key)
@@ -980,7 +1689,8 @@ func TestParsingHashLiteralsBooleanKeys(t *testing.T) {
 		false: "false",
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.Boolean)
 		if !ok {
 			t.Errorf("key is not ast.BooleanLiteral. got=%T", key)
@@ -1041,7 +1751,8 @@ func TestParsingHashLiteralWithExpression(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
@@ -1058,3 +1769,3991 @@ func TestParsingHashLiteralWithExpression(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestLetArrayDestructuring(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedElements []string
+		expectedRest     string
+		expectedString   string
+	}{
+		{"let [a, b] = arr;", []string{"a", "b"}, "", "let [a, b] = arr;"},
+		{"let [head, ...tail] = arr;", []string{"head"}, "tail", "let [head, ...tail] = arr;"},
+		{"let [...all] = arr;", []string{}, "all", "let [...all] = arr;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.LetStatement. got=%T", program.Statements[0])
+		}
+
+		pattern, ok := stmt.Pattern.(*ast.ArrayPattern)
+		if !ok {
+			t.Fatalf("stmt.Pattern not *ast.ArrayPattern. got=%T", stmt.Pattern)
+		}
+
+		if len(pattern.Elements) != len(tt.expectedElements) {
+			t.Fatalf("wrong number of elements. got=%d, expected=%d", len(pattern.Elements), len(tt.expectedElements))
+		}
+
+		for i, name := range tt.expectedElements {
+			testIdentifier(t, pattern.Elements[i], name)
+		}
+
+		if tt.expectedRest == "" {
+			if pattern.Rest != nil {
+				t.Errorf("pattern.Rest is not nil. got=%q", pattern.Rest)
+			}
+		} else {
+			testIdentifier(t, pattern.Rest, tt.expectedRest)
+		}
+
+		testIdentifier(t, stmt.Value, "arr")
+
+		if program.String() != tt.expectedString {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expectedString, program.String())
+		}
+	}
+}
+
+func TestLetHashDestructuring(t *testing.T) {
+	input := "let {x, y} = point;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	pattern, ok := stmt.Pattern.(*ast.HashPattern)
+	if !ok {
+		t.Fatalf("stmt.Pattern not *ast.HashPattern. got=%T", stmt.Pattern)
+	}
+
+	if len(pattern.Keys) != 2 {
+		t.Fatalf("wrong number of keys. got=%d, expected=2", len(pattern.Keys))
+	}
+
+	testIdentifier(t, pattern.Keys[0], "x")
+	testIdentifier(t, pattern.Keys[1], "y")
+	testIdentifier(t, stmt.Value, "point")
+
+	if program.String() != input {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", input, program.String())
+	}
+}
+
+func TestLetDestructuringErrors(t *testing.T) {
+	tests := []string{
+		"let [a, 1] = arr;",
+		"let [...rest, a] = arr;",
+		"let {x y} = point;",
+	}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestPipeExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 |> add(2)", "add(1, 2)"},
+		{"x |> f", "f(x)"},
+		{"1 |> add(2) |> mul(3)", "mul(add(1, 2), 3)"},
+		{"a + 1 |> f()", "f((a + 1))"},
+		{"xs |> map(fn(x) { x * 2 }) |> len", "len(map(xs, fn(x)(x * 2)))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.CallExpression); !ok {
+			t.Fatalf("stmt.Expression is not *ast.CallExpression. got=%T", stmt.Expression)
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestPipeExpressionEquivalentToCall(t *testing.T) {
+	piped := New(lexer.New("1 |> add(2)")).ParseProgram()
+	called := New(lexer.New("add(1, 2)")).ParseProgram()
+
+	if piped.String() != called.String() {
+		t.Errorf("piped=%q, called=%q", piped.String(), called.String())
+	}
+}
+
+func TestPipeExpressionInvalidTarget(t *testing.T) {
+	l := lexer.New("1 |> 2")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("expected 1 parser error, got=%d", len(p.Errors()))
+	}
+
+	expected := "right side of |> must be a call or identifier, got 2"
+	if p.Errors()[0] != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, p.Errors()[0])
+	}
+}
+
+func TestRangeExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input     string
+		start     interface{}
+		end       interface{}
+		exclusive bool
+	}{
+		{"1..10", 1, 10, false},
+		{"a..b", "a", "b", false},
+		{"0..<n", 0, "n", true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		rangeExp, ok := stmt.Expression.(*ast.RangeExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.RangeExpression. got=%T", stmt.Expression)
+		}
+
+		testLiteralExpression(t, rangeExp.Start, tt.start)
+		testLiteralExpression(t, rangeExp.End, tt.end)
+
+		if rangeExp.Exclusive != tt.exclusive {
+			t.Errorf("rangeExp.Exclusive wrong. expected=%t, got=%t", tt.exclusive, rangeExp.Exclusive)
+		}
+	}
+}
+
+func TestRangeExpressionPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1..10", "(1..10)"},
+		{"1..n + 1", "(1..(n + 1))"},
+		{"arr[1..3]", "(arr[(1..3)])"},
+		{"0..<len(xs)", "(0..<len(xs))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestUnlessExpression(t *testing.T) {
+	input := `unless (done) { retry }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain exactly 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	expression, ok := stmt.Expression.(*ast.UnlessExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.UnlessExpression. got=%T", stmt.Expression)
+	}
+
+	testIdentifier(t, expression.Condition, "done")
+
+	if len(expression.Consequence.Statements) != 1 {
+		t.Fatalf("consequence is not 1 statement. got=%d", len(expression.Consequence.Statements))
+	}
+
+	consequence := expression.Consequence.Statements[0].(*ast.ExpressionStatement)
+	testIdentifier(t, consequence.Expression, "retry")
+
+	if expression.Alternative != nil {
+		t.Errorf("expression.Alternative was not nil. got=%+v", expression.Alternative)
+	}
+
+	if program.String() != "unlessdone retry" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestUnlessElseExpression(t *testing.T) {
+	input := `unless (x < y) { x } else { y }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	expression, ok := stmt.Expression.(*ast.UnlessExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.UnlessExpression. got=%T", stmt.Expression)
+	}
+
+	testInfixExpression(t, expression.Condition, "x", "<", "y")
+
+	consequence := expression.Consequence.Statements[0].(*ast.ExpressionStatement)
+	testIdentifier(t, consequence.Expression, "x")
+
+	if expression.Alternative == nil || len(expression.Alternative.Statements) != 1 {
+		t.Fatalf("expression.Alternative does not contain 1 statement. got=%+v", expression.Alternative)
+	}
+
+	alternative := expression.Alternative.Statements[0].(*ast.ExpressionStatement)
+	testIdentifier(t, alternative.Expression, "y")
+}
+
+func TestWhileExpression(t *testing.T) {
+	input := `while (x < 10) { x }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	expression, ok := stmt.Expression.(*ast.WhileExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.WhileExpression. got=%T", stmt.Expression)
+	}
+
+	testInfixExpression(t, expression.Condition, "x", "<", 10)
+
+	if len(expression.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statement. got=%d", len(expression.Body.Statements))
+	}
+}
+
+func TestDoWhileExpression(t *testing.T) {
+	tests := []struct {
+		input          string
+		bodyStatements int
+	}{
+		{"do {} while (x)", 0},
+		{"do {} while (x);", 0},
+		{"do { x; break; } while (true);", 2},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		expression, ok := stmt.Expression.(*ast.DoWhileExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.DoWhileExpression. got=%T", stmt.Expression)
+		}
+
+		if len(expression.Body.Statements) != tt.bodyStatements {
+			t.Fatalf("body has wrong number of statements. got=%d, expected=%d",
+				len(expression.Body.Statements), tt.bodyStatements)
+		}
+
+		if tt.bodyStatements == 2 {
+			if _, ok := expression.Body.Statements[1].(*ast.BreakStatement); !ok {
+				t.Errorf("body.Statements[1] is not ast.BreakStatement. got=%T", expression.Body.Statements[1])
+			}
+			testBooleanLiteral(t, expression.Condition, true)
+		} else {
+			testIdentifier(t, expression.Condition, "x")
+		}
+	}
+}
+
+func TestDoWhileExpressionErrors(t *testing.T) {
+	tests := []string{
+		"do x while (y)",
+		"do {} (y)",
+		"do {} while y",
+	}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d", len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statement. got=%d", len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestMacroLiteralSingleParameter(t *testing.T) {
+	input := `macro(x) { x }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 1 {
+		t.Fatalf("macro literal parameters wrong. want 1, got=%d", len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statement. got=%d", len(macro.Body.Statements))
+	}
+}
+
+func TestQuoteUnquoteParsing(t *testing.T) {
+	input := `quote(1 + unquote(2 + 3))`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	quote, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	testIdentifier(t, quote.Function, "quote")
+
+	if len(quote.Arguments) != 1 {
+		t.Fatalf("wrong number of arguments. got=%d", len(quote.Arguments))
+	}
+
+	infix, ok := quote.Arguments[0].(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("argument is not ast.InfixExpression. got=%T", quote.Arguments[0])
+	}
+
+	testIntegerLiteral(t, infix.Left, 1)
+
+	unquote, ok := infix.Right.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("infix.Right is not ast.CallExpression. got=%T", infix.Right)
+	}
+
+	testIdentifier(t, unquote.Function, "unquote")
+	testInfixExpression(t, unquote.Arguments[0], 2, "+", 3)
+}
+
+func TestSpreadElementParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		spreads  []bool
+	}{
+		{"[...xs]", "[...xs]", []bool{true}},
+		{"[...xs, 4]", "[...xs, 4]", []bool{true, false}},
+		{"[...a, 3, ...b]", "[...a, 3, ...b]", []bool{true, false, true}},
+		{"f(...args)", "f(...args)", []bool{true}},
+		{"f(1, ...rest(xs))", "f(1, ...rest(xs))", []bool{false, true}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		var elements []ast.Expression
+		switch exp := stmt.Expression.(type) {
+		case *ast.ArrayLiteral:
+			elements = exp.Elements
+		case *ast.CallExpression:
+			elements = exp.Arguments
+		default:
+			t.Fatalf("unexpected expression type %T", stmt.Expression)
+		}
+
+		if len(elements) != len(tt.spreads) {
+			t.Fatalf("wrong number of elements. got=%d, expected=%d", len(elements), len(tt.spreads))
+		}
+
+		for i, isSpread := range tt.spreads {
+			_, ok := elements[i].(*ast.SpreadElement)
+			if ok != isSpread {
+				t.Errorf("elements[%d] spread wrong. expected=%t, got=%T", i, isSpread, elements[i])
+			}
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestRawStringLiteral(t *testing.T) {
+	input := "`{\"path\": \"C:\\\\tmp\",\n\"ok\": true}`"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := "{\"path\": \"C:\\\\tmp\",\n\"ok\": true}"
+	if literal.Value != expected {
+		t.Errorf("literal.Value not %q. got=%q", expected, literal.Value)
+	}
+}
+
+func TestUnterminatedRawStringLiteral(t *testing.T) {
+	l := lexer.New("let s = `oops;")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors for unterminated raw string")
+	}
+}
+
+func TestArrowFunctionParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+		expected       string
+	}{
+		{"(x) => x", []string{"x"}, "fn(x)return x;"},
+		{"(a, b) => a + b", []string{"a", "b"}, "fn(a, b)return (a + b);"},
+		{"() => { return 1; }", []string{}, "fn()return 1;"},
+		{"map(xs, (x) => x * 2)", nil, "map(xs, fn(x)return (x * 2);)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+
+		if tt.expectedParams == nil {
+			continue
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("wrong number of parameters. expected=%d, got=%d", len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+
+		if len(function.Body.Statements) != 1 {
+			t.Fatalf("function.Body.Statements has not 1 statement. got=%d", len(function.Body.Statements))
+		}
+
+		if _, ok := function.Body.Statements[0].(*ast.ReturnStatement); !ok {
+			t.Errorf("body statement is not ast.ReturnStatement. got=%T", function.Body.Statements[0])
+		}
+	}
+}
+
+func TestArrowFunctionDoesNotBreakGroupedExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(x)", "x"},
+		{"(a) + b", "(a + b)"},
+		{"(a + b) * c", "((a + b) * c)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestParsingHashLiteralsIdentifierKeys(t *testing.T) {
+	input := `{a: 1, (b): 2}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not *ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 2 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d, expected=2", len(hash.Pairs))
+	}
+
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
+		switch key := key.(type) {
+		case *ast.StringLiteral:
+			testStringLiteral(t, key, "a")
+			testIntegerLiteral(t, value, 1)
+		case *ast.Identifier:
+			testIdentifier(t, key, "b")
+			testIntegerLiteral(t, value, 2)
+		default:
+			t.Errorf("unexpected key type %T", key)
+		}
+	}
+}
+
+func TestParsingHashLiteralsDuplicateKeys(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`{"a": 1, "a": 2}`, `duplicate key "a" in hash literal`},
+		{`{a: 1, "a": 2}`, `duplicate key "a" in hash literal`},
+		{`{1: "one", 2: "two", 1: "uno"}`, `duplicate key 1 in hash literal`},
+		{`{true: 1, false: 0, true: 2}`, `duplicate key true in hash literal`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) != 1 {
+			t.Fatalf("expected 1 parser error for %q, got=%d: %v", tt.input, len(p.Errors()), p.Errors())
+		}
+
+		if p.Errors()[0] != tt.expectedError {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedError, p.Errors()[0])
+		}
+	}
+}
+
+func TestParsingHashLiteralsDistinctKeys(t *testing.T) {
+	inputs := []string{
+		`{"a": 1, "b": 2}`,
+		`{1: 1, "1": 2, true: 3}`,
+		`{x: 1, (x): 2}`,
+		`{f(): 1, f(): 2}`,
+	}
+
+	for _, input := range inputs {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+		checkParserErrors(t, p)
+	}
+}
+
+func TestParsingHashLiteralsPreserveOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{1:1, 2:2}", "{1:1, 2:2}"},
+		{`{"b": 2, "a": 1, "c": 3}`, "{b:2, a:1, c:3}"},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 10; i++ {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if program.String() != tt.expected {
+				t.Fatalf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+			}
+		}
+	}
+}
+
+func TestIntegerLiteralOutOfRange(t *testing.T) {
+	tests := []string{
+		"99999999999999999999",
+		"let x = 9223372036854775808;",
+		"1 + 99999999999999999999",
+	}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 1 {
+			t.Fatalf("expected 1 parser error for %q, got=%d: %v", input, len(p.Errors()), p.Errors())
+		}
+
+		if !strings.HasPrefix(p.Errors()[0], "integer literal out of range") {
+			t.Errorf("wrong error. got=%q", p.Errors()[0])
+		}
+
+		ast.Walk(program, func(node ast.Node) bool {
+			if literal, ok := node.(*ast.IntegerLiteral); ok && literal.Value == 0 {
+				t.Errorf("out of range literal was stored as 0 in %q", input)
+			}
+			return true
+		})
+	}
+}
+
+func TestOctalIntegerLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0", 0},
+		{"0755", 493},
+		{"007", 7},
+		{"00", 0},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("%q: exp is not *ast.IntegerLiteral. got=%T", tt.input, stmt.Expression)
+		}
+		if literal.Value != tt.expected || literal.TokenLiteral() != tt.input {
+			t.Errorf("%q: wrong literal. expected value=%d, got=%d (%s)", tt.input, tt.expected, literal.Value, literal.TokenLiteral())
+		}
+	}
+}
+
+func TestInvalidOctalIntegerLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"08", `invalid digit '8' in octal literal 08`},
+		{"let x = 0791;", `invalid digit '9' in octal literal 0791`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.DetailedErrors()
+		if len(errors) != 1 {
+			t.Fatalf("expected 1 parser error for %q, got=%d: %v", tt.input, len(errors), p.Errors())
+		}
+		if errors[0].Message != tt.expected || errors[0].Kind != InvalidInteger {
+			t.Errorf("wrong error for %q. got=%+v", tt.input, errors[0])
+		}
+	}
+}
+
+func TestNegativeIntegerLiteralIsPrefixExpression(t *testing.T) {
+	l := lexer.New("-5")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	prefix, ok := stmt.Expression.(*ast.PrefixExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.PrefixExpression. got=%T", stmt.Expression)
+	}
+
+	if prefix.Operator != "-" {
+		t.Errorf("prefix.Operator is not '-'. got=%q", prefix.Operator)
+	}
+
+	testIntegerLiteral(t, prefix.Right, 5)
+}
+
+func TestCustomInfixOperator(t *testing.T) {
+	tests := []struct {
+		precedence int
+		input      string
+		expected   string
+	}{
+		{PRODUCT, "a + b @ c", "(a + (b @ c))"},
+		{PRODUCT, "a @ b @ c", "((a @ b) @ c)"},
+		{SUM, "a * b @ c", "((a * b) @ c)"},
+		{CALL, "-a @ b", "(-(a @ b))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.SetPrecedence(token.AT, tt.precedence)
+		p.RegisterInfix(token.AT, p.parseInfixExpression)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestCustomStatement(t *testing.T) {
+	l := lexer.New("@ a + 1; let b = 2; @ b")
+	p := New(l)
+	p.RegisterStatement(token.AT, func() ast.Statement {
+		stmt := &ast.ExpressionStatement{Token: p.curToken}
+		emit := &ast.Identifier{Token: p.curToken, Value: "emit"}
+
+		p.nextToken()
+		argument := p.parseExpression(LOWEST)
+		stmt.Expression = &ast.CallExpression{Token: stmt.Token, Function: emit, Arguments: []ast.Expression{argument}}
+
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		return stmt
+	})
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := "emit((a + 1))let b = 2;emit(b)"
+	if program.String() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.String())
+	}
+
+	other := New(lexer.New("@ a"))
+	other.ParseProgram()
+	if len(other.Errors()) == 0 {
+		t.Errorf("custom statement leaked between parsers")
+	}
+}
+
+func TestSetPrecedenceIsPerParser(t *testing.T) {
+	custom := New(lexer.New(""))
+	custom.SetPrecedence(token.PLUS, PRODUCT)
+
+	l := lexer.New("a + b * c")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "(a + (b * c))" {
+		t.Errorf("precedence leaked between parsers. got=%q", program.String())
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	p := New(lexer.New(""))
+
+	if p.Precedence(token.ASTERISK) <= p.Precedence(token.PLUS) {
+		t.Errorf("* does not bind tighter than +. got %d and %d", p.Precedence(token.ASTERISK), p.Precedence(token.PLUS))
+	}
+
+	tests := []struct {
+		tokenType    token.TokenType
+		expected     int
+		expectedName string
+	}{
+		{token.PLUS, SUM, "SUM"},
+		{token.LT, LESSGREATER, "LESSGREATER"},
+		{token.LPAREN, CALL, "CALL"},
+		{token.AND_KW, AND, "AND"},
+		{token.COMMA, LOWEST, "LOWEST"},
+		{token.IDENT, LOWEST, "LOWEST"},
+	}
+
+	for _, tt := range tests {
+		precedence := p.Precedence(tt.tokenType)
+		if precedence != tt.expected {
+			t.Errorf("Precedence(%s) wrong. expected=%d, got=%d", tt.tokenType, tt.expected, precedence)
+		}
+
+		if name := PrecedenceName(precedence); name != tt.expectedName {
+			t.Errorf("PrecedenceName(%d) wrong. expected=%q, got=%q", precedence, tt.expectedName, name)
+		}
+	}
+
+	p.SetPrecedence(token.PLUS, PRODUCT)
+	if p.Precedence(token.PLUS) != PRODUCT {
+		t.Errorf("Precedence does not reflect SetPrecedence. got=%d", p.Precedence(token.PLUS))
+	}
+
+	if name := PrecedenceName(42); name != "42" {
+		t.Errorf("PrecedenceName(42) wrong. got=%q", name)
+	}
+}
+
+func TestBlockExpressionParsing(t *testing.T) {
+	input := `let y = { let t = compute(); t * 2 };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	block, ok := stmt.Value.(*ast.BlockExpression)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.BlockExpression. got=%T", stmt.Value)
+	}
+
+	if len(block.Block.Statements) != 2 {
+		t.Fatalf("block has wrong number of statements. got=%d", len(block.Block.Statements))
+	}
+
+	last := block.Block.Statements[1].(*ast.ExpressionStatement)
+	testInfixExpression(t, last.Expression, "t", "*", 2)
+
+	expected := "let y = {let t = compute();(t * 2)};"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestBlockExpressionEarlyReturn(t *testing.T) {
+	input := `let y = { return 1; dead(); 2 };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	block := program.Statements[0].(*ast.LetStatement).Value.(*ast.BlockExpression)
+	if len(block.Block.Statements) != 3 {
+		t.Fatalf("block has wrong number of statements. got=%d", len(block.Block.Statements))
+	}
+
+	returnStmt, ok := block.Block.Statements[0].(*ast.ReturnStatement)
+	if !ok {
+		t.Fatalf("first statement not *ast.ReturnStatement. got=%T", block.Block.Statements[0])
+	}
+	testIntegerLiteral(t, returnStmt.ReturnValue, 1)
+
+	expected := "let y = {return 1;dead()2};"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestBareReturnStatement(t *testing.T) {
+	tests := []struct {
+		input      string
+		statements int
+	}{
+		{"{ return; x }", 1},
+		{"{ return }", 1},
+		{"fn() { if (a) { return } b; }", 1},
+		{"return", 1},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var returns []*ast.ReturnStatement
+		ast.Walk(program, func(node ast.Node) bool {
+			if stmt, ok := node.(*ast.ReturnStatement); ok {
+				returns = append(returns, stmt)
+			}
+			return true
+		})
+
+		if len(returns) != 1 || returns[0].ReturnValue != nil {
+			t.Errorf("%q: expected one bare return. got=%v", tt.input, returns)
+		}
+		if len(program.Statements) != tt.statements {
+			t.Errorf("%q: wrong number of statements. got=%d", tt.input, len(program.Statements))
+		}
+	}
+}
+
+func TestParsingHashLiteralShorthand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{x}", "{x:x}"},
+		{"{x, y}", "{x:x, y:y}"},
+		{"{x, y: 2}", "{x:x, y:2}"},
+		{"{y: 2, x,}", "{y:2, x:x}"},
+		{`{"a": 1, x}`, "{a:1, x:x}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		hash, ok := stmt.Expression.(*ast.HashLiteral)
+		if !ok {
+			t.Fatalf("%q: exp is not ast.HashLiteral. got=%T", tt.input, stmt.Expression)
+		}
+
+		for _, pair := range hash.Pairs {
+			if key, ok := pair.Key.(*ast.StringLiteral); ok && key.Value == "x" {
+				testIdentifier(t, pair.Value, "x")
+			}
+		}
+
+		if hash.String() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, hash.String())
+		}
+	}
+}
+
+func TestParsingHashLiteralShorthandDuplicateKey(t *testing.T) {
+	l := lexer.New(`{x, "x": 1}`)
+	p := New(l)
+	p.ParseProgram()
+
+	expected := `duplicate key "x" in hash literal`
+	if len(p.Errors()) != 1 || p.Errors()[0] != expected {
+		t.Errorf("expected error %q, got=%q", expected, p.Errors())
+	}
+}
+
+func TestBraceExpressionDisambiguation(t *testing.T) {
+	tests := []struct {
+		input   string
+		isBlock bool
+	}{
+		{"{}", false},
+		{`{"a": 1}`, false},
+		{"{(x): 1}", false},
+		{"{f(1): [1, 2]}", false},
+		{"{ x }", false},
+		{"{ x; }", true},
+		{"{ x + 1 }", true},
+		{"{ f(1); 2 }", true},
+		{"{ let h = {}; h }", true},
+		{`{ {"a": 1} }`, true},
+		{"{ return 1; }", true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		_, isBlock := stmt.Expression.(*ast.BlockExpression)
+		_, isHash := stmt.Expression.(*ast.HashLiteral)
+
+		if isBlock != tt.isBlock || isHash == tt.isBlock {
+			t.Errorf("wrong expression for %q. got=%T", tt.input, stmt.Expression)
+		}
+	}
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedTarget string
+		expectedString string
+	}{
+		{"x = 5;", "*ast.Identifier", "x = 5;"},
+		{"arr[0] = 1;", "*ast.IndexExpression", "(arr[0]) = 1;"},
+		{"grid[i][j] = 0", "*ast.IndexExpression", "((grid[i])[j]) = 0;"},
+		{`obj.name = "x";`, "*ast.DotExpression", "(obj.name) = x;"},
+		{"a.b[c].d = e + 1;", "*ast.DotExpression", "(((a.b)[c]).d) = (e + 1);"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.AssignStatement)
+		if !ok {
+			t.Fatalf("stmt is not *ast.AssignStatement. got=%T", program.Statements[0])
+		}
+
+		if target := fmt.Sprintf("%T", stmt.Target); target != tt.expectedTarget {
+			t.Errorf("stmt.Target has wrong type. expected=%s, got=%s", tt.expectedTarget, target)
+		}
+
+		if program.String() != tt.expectedString {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expectedString, program.String())
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a = b = c;", "a = (b = c);"},
+		{"a = b = c = 1 + 2;", "a = (b = (c = (1 + 2)));"},
+		{"while ((line = next()) != null) { puts(line) }", "while((line = next()) != null) puts(line)"},
+		{"if (x = f()) { x }", "if(x = f()) x"},
+		{"f(a = 1, b[0] = 2)", "f((a = 1), ((b[0]) = 2))"},
+		{"let x = y = 2;", "let x = (y = 2);"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestMistypedKeywordSuggestions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"retrun x;", "unexpected identifier retrun, did you mean `return`?"},
+		{"if (x) { 1 } esle { 2 }", "unexpected identifier esle, did you mean `else`?"},
+		{"for (x inn xs) { x }", "expected next token to be IN, got 'inn' (IDENT) instead, did you mean `in`?"},
+		{"try { a } cacth (e) { b }", "expected next token to be CATCH, got 'cacth' (IDENT) instead, did you mean `catch`?"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expectedError {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expectedError, p.Errors())
+		}
+	}
+}
+
+func TestNoKeywordSuggestions(t *testing.T) {
+	tests := []string{
+		"retrun;",
+		"let retrun = 1; retrun + 1",
+		"counter x;",
+		"for (x of xs) { x }",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		for _, err := range p.Errors() {
+			if strings.Contains(err, "did you mean") {
+				t.Errorf("unexpected suggestion for %q: %q", input, err)
+			}
+		}
+	}
+}
+
+func TestInvalidAssignExpressionTarget(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"a = 1 = 2;", "invalid assignment target: 1"},
+		{"a = f() = 2;", "invalid assignment target: f()"},
+		{"while ((x + 1 = 2)) {}", "invalid assignment target: (x + 1)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) != 1 || p.Errors()[0] != tt.expectedError {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expectedError, p.Errors())
+		}
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"1 = 2;", "invalid assignment target: 1"},
+		{"f() = 2;", "invalid assignment target: f()"},
+		{"a + b = 2;", "invalid assignment target: (a + b)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 1 {
+			t.Fatalf("expected 1 parser error for %q, got=%d: %v", tt.input, len(p.Errors()), p.Errors())
+		}
+
+		if p.Errors()[0] != tt.expectedError {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedError, p.Errors()[0])
+		}
+
+		if len(program.Statements) != 0 {
+			t.Errorf("expected no statements, got=%d", len(program.Statements))
+		}
+	}
+}
+
+func TestTrailingBlockArguments(t *testing.T) {
+	tests := []struct {
+		input      string
+		equivalent string
+	}{
+		{"each(xs) { |x| print(x) }", "each(xs, fn(x) { print(x) })"},
+		{"reduce(xs, 0) { |acc, x| acc + x }", "reduce(xs, 0, fn(acc, x) { acc + x })"},
+		{"loop() { tick() }", "loop(fn() { tick() })"},
+		{"later() { || done() }", "later(fn() { done() })"},
+		{"each(xs) {}", "each(xs, fn() {})"},
+		{"let ys = map(xs) { |x| x * 2 };", "let ys = map(xs, fn(x) { x * 2 });"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		equivalent := New(lexer.New(tt.equivalent)).ParseProgram()
+		if program.String() != equivalent.String() {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, equivalent.String(), program.String())
+		}
+
+		call := program.Statements[0]
+		if let, ok := call.(*ast.LetStatement); ok {
+			call = &ast.ExpressionStatement{Expression: let.Value}
+		}
+		arguments := call.(*ast.ExpressionStatement).Expression.(*ast.CallExpression).Arguments
+		if _, ok := arguments[len(arguments)-1].(*ast.FunctionLiteral); !ok {
+			t.Errorf("%q: last argument is not *ast.FunctionLiteral. got=%T", tt.input, arguments[len(arguments)-1])
+		}
+	}
+}
+
+func TestCallWithoutTrailingBlock(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"each(xs)", "each(xs)"},
+		{"each(xs)\n{ x }", "each(xs){x:x}"},
+		{"each(xs); { y; }", "each(xs){y}"},
+		{"match len(xs) { 0 => a, _ => b }", "match len(xs) { 0 => a, _ => b }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestTrailingBlockParameterErrors(t *testing.T) {
+	l := lexer.New("each(xs) { |x, 1| x }")
+	p := New(l)
+	p.ParseProgram()
+
+	expected := "expected next token to be IDENT, got '1' (INT) instead"
+	if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+		t.Errorf("expected error %q, got=%q", expected, p.Errors())
+	}
+}
+
+func TestTrailingBlockAfterNamedArguments(t *testing.T) {
+	l := lexer.New("each(xs, n: 1) { |x| x }")
+	p := New(l)
+	p.ParseProgram()
+
+	expected := []string{"trailing block follows named argument"}
+	if !reflect.DeepEqual(p.Errors(), expected) {
+		t.Errorf("errors wrong. expected=%q, got=%q", expected, p.Errors())
+	}
+
+	errors := p.DetailedErrors()
+	if len(errors) > 0 && (errors[0].Line != 1 || errors[0].Column != 16) {
+		t.Errorf("error position wrong. expected 1:16, got=%d:%d", errors[0].Line, errors[0].Column)
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		start    string
+		stop     string
+		step     string
+		expected string
+	}{
+		{"arr[0:10:2]", "0", "10", "2", "(arr[0:10:2])"},
+		{"arr[::2]", "", "", "2", "(arr[::2])"},
+		{"arr[1:]", "1", "", "", "(arr[1:])"},
+		{"arr[:n - 1]", "", "(n - 1)", "", "(arr[:(n - 1)])"},
+		{"arr[:]", "", "", "", "(arr[:])"},
+		{"arr[a:b:]", "a", "b", "", "(arr[a:b])"},
+		{"arr[-1::-1]", "(-1)", "", "(-1)", "(arr[(-1)::(-1)])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		slice, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("%q: exp is not *ast.SliceExpression. got=%T", tt.input, stmt.Expression)
+		}
+
+		for _, component := range []struct {
+			name     string
+			node     ast.Expression
+			expected string
+		}{
+			{"Start", slice.Start, tt.start},
+			{"Stop", slice.Stop, tt.stop},
+			{"Step", slice.Step, tt.step},
+		} {
+			got := ""
+			if component.node != nil {
+				got = component.node.String()
+			}
+			if got != component.expected {
+				t.Errorf("%q: slice.%s wrong. expected=%q, got=%q", tt.input, component.name, component.expected, got)
+			}
+		}
+
+		if slice.String() != tt.expected {
+			t.Errorf("%q: slice.String() wrong. expected=%q, got=%q", tt.input, tt.expected, slice.String())
+		}
+	}
+}
+
+func TestSliceExpressionZeroStep(t *testing.T) {
+	tests := []string{"arr[0:10:0]", "arr[::0]"}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		expected := "slice step cannot be zero"
+		if len(p.Errors()) != 1 || p.Errors()[0] != expected {
+			t.Errorf("%q: expected error %q, got=%q", input, expected, p.Errors())
+		}
+	}
+}
+
+func TestDotExpressionParsing(t *testing.T) {
+	l := lexer.New("a.b.c(1)")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := "((a.b).c)(1)"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestNewlineTerminatedStatements(t *testing.T) {
+	input := `let x = 5
+let add = fn(a, b) {
+  let sum = a + b
+  return sum
+}
+let h = {
+  "one": 1,
+  "two": 2
+}
+x = add(x,
+  10)
+if (x > 10) {
+  break
+} else {
+  x
+}
+add(x, [
+  1,
+  2
+])
+`
+
+	l := lexer.New(input, lexer.WithNewlines())
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := []string{
+		"let x = 5;",
+		"let add = fn(a, b)let sum = (a + b);return sum;;",
+		"let h = {one:1, two:2};",
+		"x = add(x, 10);",
+		"if(x > 10) break;else x",
+		"add(x, [1, 2])",
+	}
+
+	if len(program.Statements) != len(expected) {
+		t.Fatalf("program.Statements has wrong length. expected=%d, got=%d: %q",
+			len(expected), len(program.Statements), program.String())
+	}
+
+	for i, stmt := range program.Statements {
+		if stmt.String() != expected[i] {
+			t.Errorf("statement %d wrong. expected=%q, got=%q", i, expected[i], stmt.String())
+		}
+	}
+}
+
+func TestNewlinesIgnoredInsideBrackets(t *testing.T) {
+	input := "foo(\n1 +\n2,\n[3,\n4]\n)"
+
+	l := lexer.New(input, lexer.WithNewlines())
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	expected := "foo((1 + 2), [3, 4])"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestNewlinesBeforeDotContinueChain(t *testing.T) {
+	input := `let b = builder
+  .add(1)
+
+  .build()
+b
+c.d
+`
+
+	l := lexer.New(input, lexer.WithNewlines())
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := []string{
+		"let b = ((builder.add)(1).build)();",
+		"b",
+		"(c.d)",
+	}
+
+	if len(program.Statements) != len(expected) {
+		t.Fatalf("program.Statements has wrong length. got=%d: %q", len(program.Statements), program.String())
+	}
+
+	for i := range expected {
+		if program.Statements[i].String() != expected[i] {
+			t.Errorf("statement %d wrong. expected=%q, got=%q", i, expected[i], program.Statements[i].String())
+		}
+	}
+
+	call, ok := program.Statements[0].(*ast.LetStatement).Value.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("value is not ast.CallExpression. got=%T", program.Statements[0].(*ast.LetStatement).Value)
+	}
+	if _, ok := call.Function.(*ast.DotExpression); !ok {
+		t.Errorf("call.Function is not ast.DotExpression. got=%T", call.Function)
+	}
+}
+
+func TestErrorMessagesContainLiteral(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let = 5;", "expected next token to be IDENT, got '=' (ASSIGN) instead"},
+		{"let x 5;", "expected next token to be ASSIGN, got '5' (INT) instead"},
+		{"}", "no prefix parse function for '}' (RBRACE) found"},
+		{"* 5", "no prefix parse function for '*' (ASTERISK) found"},
+		{"let x = $;", "no prefix parse function for '$' (ILLEGAL) found"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+
+		if p.Errors()[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expectedError, p.Errors()[0])
+		}
+	}
+}
+
+func TestResetMatchesFreshParser(t *testing.T) {
+	inputs := []string{
+		"let x = 5 * (2 + y);",
+		"let = 5;",
+		`fn(a, b) { if (a > b) { a } else { b } }(1, 2)`,
+		`{"one": 1, "two": [1, 2, 3][0]}`,
+		"1 < 2 < 3;",
+		"1;",
+		"each(xs) { |x| x }",
+	}
+
+	reused := New(lexer.New(""))
+	for _, input := range inputs {
+		fresh := New(lexer.New(input))
+		expected := fresh.ParseProgram()
+
+		// as left behind by a parse that was abandoned inside a match subject
+		reused.noTrailingBlock = true
+		reused.Reset(lexer.New(input))
+		actual := reused.ParseProgram()
+
+		if !ast.Equal(expected, actual) {
+			t.Errorf("reset parser output differs for %q. expected=%q, got=%q", input, expected.String(), actual.String())
+		}
+
+		if strings.Join(fresh.Errors(), "\n") != strings.Join(reused.Errors(), "\n") {
+			t.Errorf("reset parser errors differ for %q. expected=%v, got=%v", input, fresh.Errors(), reused.Errors())
+		}
+
+		if !reflect.DeepEqual(fresh.Warnings(), reused.Warnings()) {
+			t.Errorf("reset parser warnings differ for %q. expected=%v, got=%v", input, fresh.Warnings(), reused.Warnings())
+		}
+	}
+}
+
+const benchmarkInput = "let add = fn(a, b) { a + b }; add(1, 2 * 3);"
+
+func BenchmarkNew(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := New(lexer.New(benchmarkInput))
+		p.ParseProgram()
+	}
+}
+
+func BenchmarkReset(b *testing.B) {
+	b.ReportAllocs()
+	p := New(lexer.New(""))
+	for i := 0; i < b.N; i++ {
+		p.Reset(lexer.New(benchmarkInput))
+		p.ParseProgram()
+	}
+}
+
+func TestIndexExpressionFromEnd(t *testing.T) {
+	tests := []struct {
+		input    string
+		fromEnd  bool
+		expected string
+	}{
+		{"arr[-1]", true, "(arr[(-1)])"},
+		{"arr[-10]", true, "(arr[(-10)])"},
+		{"arr[i]", false, "(arr[i])"},
+		{"arr[1]", false, "(arr[1])"},
+		{"arr[-i]", false, "(arr[(-i)])"},
+		{"arr[-1 + 2]", false, "(arr[((-1) + 2)])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+		}
+
+		if indexExp.FromEnd != tt.fromEnd {
+			t.Errorf("indexExp.FromEnd wrong for %q. expected=%t, got=%t", tt.input, tt.fromEnd, indexExp.FromEnd)
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestNamedCallArguments(t *testing.T) {
+	tests := []struct {
+		input         string
+		positional    []interface{}
+		named         []string
+		namedValues   []interface{}
+		expectedWrite string
+	}{
+		{`connect(a, 8080)`, []interface{}{"a", 8080}, nil, nil, "connect(a, 8080)"},
+		{`connect(host: a, port: 8080)`, nil, []string{"host", "port"}, []interface{}{"a", 8080}, "connect(host: a, port: 8080)"},
+		{`connect(a, port: 8080)`, []interface{}{"a"}, []string{"port"}, []interface{}{8080}, "connect(a, port: 8080)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		call, ok := stmt.Expression.(*ast.CallExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+		}
+
+		if len(call.Arguments) != len(tt.positional) {
+			t.Fatalf("wrong number of positional arguments. expected=%d, got=%d", len(tt.positional), len(call.Arguments))
+		}
+
+		for i, expected := range tt.positional {
+			testLiteralExpression(t, call.Arguments[i], expected)
+		}
+
+		if len(call.NamedArguments) != len(tt.named) {
+			t.Fatalf("wrong number of named arguments. expected=%d, got=%d", len(tt.named), len(call.NamedArguments))
+		}
+
+		for i, name := range tt.named {
+			testIdentifier(t, call.NamedArguments[i].Name, name)
+			testLiteralExpression(t, call.NamedArguments[i].Value, tt.namedValues[i])
+		}
+
+		if program.String() != tt.expectedWrite {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expectedWrite, program.String())
+		}
+	}
+}
+
+func TestPositionalArgumentAfterNamedArgument(t *testing.T) {
+	l := lexer.New(`connect(host: "x", 8080)`)
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+
+	expected := "positional argument 8080 follows named argument"
+	if p.Errors()[0] != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, p.Errors()[0])
+	}
+}
+
+func TestChainedComparisons(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"1 < x < 10", "chained comparison 1 < x < 10 is likely a mistake; use &&"},
+		{"a > b > c", "chained comparison a > b > c is likely a mistake; use &&"},
+		{"a == b != c", "chained comparison a == b != c is likely a mistake; use &&"},
+		{"5 > 4 == 3 < 4", ""},
+		{"1 < x + 10", ""},
+		{"a + b < c", ""},
+	}
+
+	for _, tt := range tests {
+		lenient := New(lexer.New(tt.input))
+		program := lenient.ParseProgram()
+		checkParserErrors(t, lenient)
+
+		warnings := lenient.Warnings()
+		if tt.expectedError == "" && len(warnings) != 0 {
+			t.Errorf("unexpected warnings for %q: %v", tt.input, warnings)
+		}
+		if tt.expectedError != "" {
+			if len(warnings) != 1 || warnings[0].Message != tt.expectedError {
+				t.Errorf("wrong warnings for %q. expected=%q, got=%v", tt.input, tt.expectedError, warnings)
+			}
+			if _, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression); !ok {
+				t.Errorf("%q did not parse as an infix expression: %s", tt.input, program.String())
+			}
+		}
+
+		strict := New(lexer.New(tt.input))
+		strict.StrictComparisons = true
+		strict.ParseProgram()
+
+		if tt.expectedError == "" {
+			checkParserErrors(t, strict)
+			continue
+		}
+
+		if len(strict.Errors()) != 1 {
+			t.Fatalf("expected 1 parser error for %q, got=%d: %v", tt.input, len(strict.Errors()), strict.Errors())
+		}
+
+		if strict.Errors()[0] != tt.expectedError {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedError, strict.Errors()[0])
+		}
+		if len(strict.Warnings()) != 0 {
+			t.Errorf("strict parser also warned for %q: %v", tt.input, strict.Warnings())
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input            string
+		bodyStatements   int
+		expectedBinding  string
+		handlerStatement int
+		expectedString   string
+	}{
+		{"try { risky() } catch (e) { recover(e) }", 1, "e", 1, "try {risky()} catch (e) {recover(e)}"},
+		{"try { risky(); other() } catch { 0 }", 2, "", 1, "try {risky()other()} catch {0}"},
+		{"try {} catch (err) {}", 0, "err", 0, "try {} catch (err) {}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		expression, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T", stmt.Expression)
+		}
+
+		if len(expression.Body.Statements) != tt.bodyStatements {
+			t.Errorf("body has wrong number of statements. got=%d, expected=%d",
+				len(expression.Body.Statements), tt.bodyStatements)
+		}
+
+		if tt.expectedBinding == "" {
+			if expression.Binding != nil {
+				t.Errorf("expression.Binding was not nil. got=%+v", expression.Binding)
+			}
+		} else {
+			testIdentifier(t, expression.Binding, tt.expectedBinding)
+		}
+
+		if len(expression.Handler.Statements) != tt.handlerStatement {
+			t.Errorf("handler has wrong number of statements. got=%d, expected=%d",
+				len(expression.Handler.Statements), tt.handlerStatement)
+		}
+
+		if expression.String() != tt.expectedString {
+			t.Errorf("expression.String() wrong. expected=%q, got=%q", tt.expectedString, expression.String())
+		}
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []string{
+		"try x catch {}",
+		"try {} {}",
+		"try {} catch (1) {}",
+		"try {} catch (e {}",
+		"try {} catch e",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestTupleLiterals(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedElements []string
+		expectedString   string
+	}{
+		{"()", []string{}, "()"},
+		{"(x, y)", []string{"x", "y"}, "(x, y)"},
+		{"(1, 2 * 3, f(4))", []string{"1", "(2 * 3)", "f(4)"}, "(1, (2 * 3), f(4))"},
+		{"((1, 2), 3)", []string{"(1, 2)", "3"}, "((1, 2), 3)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		tuple, ok := stmt.Expression.(*ast.TupleLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.TupleLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(tuple.Elements) != len(tt.expectedElements) {
+			t.Fatalf("len(tuple.Elements) wrong. expected=%d, got=%d", len(tt.expectedElements), len(tuple.Elements))
+		}
+
+		for i, element := range tuple.Elements {
+			if element.String() != tt.expectedElements[i] {
+				t.Errorf("tuple.Elements[%d] wrong. expected=%q, got=%q", i, tt.expectedElements[i], element.String())
+			}
+		}
+
+		if tuple.String() != tt.expectedString {
+			t.Errorf("tuple.String() wrong. expected=%q, got=%q", tt.expectedString, tuple.String())
+		}
+	}
+}
+
+func TestGroupedExpressionIsNotTuple(t *testing.T) {
+	l := lexer.New("(x)")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	testIdentifier(t, stmt.Expression, "x")
+}
+
+func TestErrorsDetailed(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x 5;",
+			"1:7: expected next token to be ASSIGN, got '5' (INT) instead\n" +
+				"let x 5;\n" +
+				"      ^\n",
+		},
+		{
+			"let a = 1;\n\tlet b = * 2;",
+			"2:10: no prefix parse function for '*' (ASTERISK) found\n" +
+				"\tlet b = * 2;\n" +
+				"\t        ^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		detailed := p.ErrorsDetailed(tt.input)
+		if detailed != tt.expected {
+			t.Errorf("ErrorsDetailed wrong for %q.\nexpected:\n%s\ngot:\n%s", tt.input, tt.expected, detailed)
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedConst      bool
+		expectedIdentifier string
+		expectedValue      interface{}
+		expectedString     string
+	}{
+		{"const x = 5;", true, "x", 5, "const x = 5;"},
+		{"const flag = true", true, "flag", true, "const flag = true;"},
+		{"let y = 5;", false, "y", 5, "let y = 5;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+
+		statement := program.Statements[0]
+		if !testLetStatement(t, statement, tt.expectedIdentifier) {
+			return
+		}
+
+		stmt := statement.(*ast.LetStatement)
+		if stmt.IsConst != tt.expectedConst {
+			t.Errorf("stmt.IsConst wrong. expected=%t, got=%t", tt.expectedConst, stmt.IsConst)
+		}
+
+		testLiteralExpression(t, stmt.Value, tt.expectedValue)
+
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.expectedString, stmt.String())
+		}
+	}
+}
+
+func TestConstStatementWithoutInitializer(t *testing.T) {
+	p := New(lexer.New("const x;"))
+	p.ParseProgram()
+
+	expected := "expected next token to be ASSIGN, got ';' (SEMICOLON) instead"
+	if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+		t.Errorf("wrong parser errors. expected first=%q, got=%v", expected, p.Errors())
+	}
+}
+
+func TestLetStatementWithoutInitializer(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedName   string
+		expectedValue  interface{}
+		expectedString string
+	}{
+		{"let x;", "x", nil, "let x;"},
+		{"let x", "x", nil, "let x;"},
+		{"let x = 5;", "x", 5, "let x = 5;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+
+		if !testLetStatement(t, program.Statements[0], tt.expectedName) {
+			return
+		}
+
+		stmt := program.Statements[0].(*ast.LetStatement)
+		if tt.expectedValue == nil {
+			if stmt.Value != nil {
+				t.Errorf("stmt.Value is not nil. got=%s", stmt.Value)
+			}
+		} else {
+			testLiteralExpression(t, stmt.Value, tt.expectedValue)
+		}
+
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.expectedString, stmt.String())
+		}
+	}
+}
+
+func TestLetStatementMultipleBindings(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedNames  []string
+		expectedValues []string
+		expectedString string
+	}{
+		{"let x = 5;", []string{"x"}, []string{"5"}, "let x = 5;"},
+		{"let a = 1, b = 2;", []string{"a", "b"}, []string{"1", "2"}, "let a = 1, b = 2;"},
+		{"let a = 1, b = a + 1, c = f(b)", []string{"a", "b", "c"}, []string{"1", "(a + 1)", "f(b)"},
+			"let a = 1, b = (a + 1), c = f(b);"},
+		{"let a, b = 2, c;", []string{"a", "b", "c"}, []string{"", "2", ""}, "let a, b = 2, c;"},
+		{"const a = 1, b = 2;", []string{"a", "b"}, []string{"1", "2"}, "const a = 1, b = 2;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.LetStatement)
+		bindings := append([]ast.LetBinding{{Name: stmt.Name, Value: stmt.Value}}, stmt.Additional...)
+
+		if len(bindings) != len(tt.expectedNames) {
+			t.Fatalf("wrong number of bindings. expected=%d, got=%d", len(tt.expectedNames), len(bindings))
+		}
+
+		for i, binding := range bindings {
+			testIdentifier(t, binding.Name, tt.expectedNames[i])
+
+			value := ""
+			if binding.Value != nil {
+				value = binding.Value.String()
+			}
+			if value != tt.expectedValues[i] {
+				t.Errorf("bindings[%d] value wrong. expected=%q, got=%q", i, tt.expectedValues[i], value)
+			}
+		}
+
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.expectedString, stmt.String())
+		}
+	}
+}
+
+func TestConstStatementMultipleBindingsRequireInitializers(t *testing.T) {
+	p := New(lexer.New("const a = 1, b;"))
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors for const binding without initializer")
+	}
+}
+
+func TestRegexLiteral(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedPattern string
+		expectedFlags   string
+	}{
+		{"/ab+c/i", "ab+c", "i"},
+		{"/[a-z]+/", "[a-z]+", ""},
+		{`/a\/b/gm`, `a\/b`, "gm"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		regex, ok := stmt.Expression.(*ast.RegexLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.RegexLiteral. got=%T", stmt.Expression)
+		}
+
+		if regex.Pattern != tt.expectedPattern {
+			t.Errorf("regex.Pattern wrong. expected=%q, got=%q", tt.expectedPattern, regex.Pattern)
+		}
+
+		if regex.Flags != tt.expectedFlags {
+			t.Errorf("regex.Flags wrong. expected=%q, got=%q", tt.expectedFlags, regex.Flags)
+		}
+
+		if regex.String() != tt.input {
+			t.Errorf("regex.String() wrong. expected=%q, got=%q", tt.input, regex.String())
+		}
+	}
+}
+
+func TestRegexLiteralAfterOperator(t *testing.T) {
+	l := lexer.New("let r = a / b / c; let s = /x/;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "let r = ((a / b) / c);let s = /x/;" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestOptionalIndexExpression(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedComputed bool
+		expectedIndex    string
+		expectedString   string
+	}{
+		{"a?.b", false, "b", "(a?.b)"},
+		{"a?.[i + 1]", true, "(i + 1)", "(a?.[(i + 1)])"},
+		{"a?.b?.c", false, "c", "((a?.b)?.c)"},
+		{"a?.[0]?.name", false, "name", "((a?.[0])?.name)"},
+		{"f(x)?.b[0]", false, "", "((f(x)?.b)[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.expectedString, stmt.String())
+		}
+
+		if tt.expectedIndex == "" {
+			continue
+		}
+
+		exp, ok := stmt.Expression.(*ast.OptionalIndexExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.OptionalIndexExpression. got=%T", stmt.Expression)
+		}
+
+		if exp.Computed != tt.expectedComputed {
+			t.Errorf("exp.Computed wrong. expected=%t, got=%t", tt.expectedComputed, exp.Computed)
+		}
+
+		if !tt.expectedComputed {
+			testIdentifier(t, exp.Index, tt.expectedIndex)
+		} else if exp.Index.String() != tt.expectedIndex {
+			t.Errorf("exp.Index wrong. expected=%q, got=%q", tt.expectedIndex, exp.Index.String())
+		}
+	}
+}
+
+func TestOptionalIndexExpressionErrors(t *testing.T) {
+	tests := []string{
+		"a?.1",
+		"a?.[1",
+		"a?.",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestNullCoalescingOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a ?? b", "(a ?? b)"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a || b ?? c", "(a || (b ?? c))"},
+		{"a ?? b || c", "((a ?? b) || c)"},
+		{"a ?? b && c", "(a ?? (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a?.b ?? 1 + 2", "((a?.b) ?? (1 + 2))"},
+		{"x == 1 ?? y", "((x == 1) ?? y)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestLabeledStatements(t *testing.T) {
+	input := `
+outer: while (true) {
+	inner: while (x) {
+		if (y) { continue inner; }
+		break outer;
+	}
+	continue;
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	outer, ok := program.Statements[0].(*ast.LabeledStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LabeledStatement. got=%T", program.Statements[0])
+	}
+	testIdentifier(t, outer.Label, "outer")
+
+	outerLoop := outer.Statement.(*ast.ExpressionStatement).Expression.(*ast.WhileExpression)
+	if len(outerLoop.Body.Statements) != 2 {
+		t.Fatalf("outer body has wrong number of statements. got=%d", len(outerLoop.Body.Statements))
+	}
+
+	inner, ok := outerLoop.Body.Statements[0].(*ast.LabeledStatement)
+	if !ok {
+		t.Fatalf("outer body statement is not ast.LabeledStatement. got=%T", outerLoop.Body.Statements[0])
+	}
+	testIdentifier(t, inner.Label, "inner")
+
+	innerLoop := inner.Statement.(*ast.ExpressionStatement).Expression.(*ast.WhileExpression)
+	ifExp := innerLoop.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+
+	cont, ok := ifExp.Consequence.Statements[0].(*ast.ContinueStatement)
+	if !ok {
+		t.Fatalf("consequence is not ast.ContinueStatement. got=%T", ifExp.Consequence.Statements[0])
+	}
+	testIdentifier(t, cont.Label, "inner")
+
+	brk, ok := innerLoop.Body.Statements[1].(*ast.BreakStatement)
+	if !ok {
+		t.Fatalf("inner body statement is not ast.BreakStatement. got=%T", innerLoop.Body.Statements[1])
+	}
+	testIdentifier(t, brk.Label, "outer")
+
+	unlabeled, ok := outerLoop.Body.Statements[1].(*ast.ContinueStatement)
+	if !ok {
+		t.Fatalf("outer body statement is not ast.ContinueStatement. got=%T", outerLoop.Body.Statements[1])
+	}
+	if unlabeled.Label != nil {
+		t.Errorf("unlabeled.Label is not nil. got=%s", unlabeled.Label)
+	}
+
+	expected := "outer: whiletrue inner: whilex ify continue inner;break outer;continue;"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestLabelWithoutStatement(t *testing.T) {
+	p := New(lexer.New("outer:"))
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+}
+
+func TestFoldConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 + 3 * 4", "14"},
+		{"(2 + 3) * 4", "20"},
+		{"10 / 3 - 1", "2"},
+		{"x + 2 * 3", "(x + 6)"},
+		{"2 * 3 + x", "(6 + x)"},
+		{"1 + 2 < 4", "(3 < 4)"},
+		{"f(1 + 1, 2 * 2)", "f(2, 4)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.FoldConstants = true
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("folded program wrong for %q. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestFoldConstantsResultIsIntegerLiteral(t *testing.T) {
+	p := New(lexer.New("2 + 3 * 4"))
+	p.FoldConstants = true
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	testIntegerLiteral(t, stmt.Expression, 14)
+}
+
+func TestFoldConstantsDivisionByZero(t *testing.T) {
+	p := New(lexer.New("1 / 0"))
+	p.FoldConstants = true
+	program := p.ParseProgram()
+
+	if program.String() != "(1 / 0)" {
+		t.Errorf("division by zero was folded. got=%q", program.String())
+	}
+
+	expected := "division by zero: (1 / 0)"
+	if len(p.Errors()) != 1 || p.Errors()[0] != expected {
+		t.Errorf("wrong parser errors. expected=[%q], got=%v", expected, p.Errors())
+	}
+}
+
+func TestFoldConstantsOffByDefault(t *testing.T) {
+	p := New(lexer.New("2 + 3 * 4; 1 / 0"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "(2 + (3 * 4))(1 / 0)" {
+		t.Errorf("program was folded by default. got=%q", program.String())
+	}
+}
+
+func TestListComprehension(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedElement  string
+		expectedVar      string
+		expectedIterable string
+		expectedFilter   string
+		expectedString   string
+	}{
+		{"[x * 2 for x in xs]", "(x * 2)", "x", "xs", "", "[(x * 2) for x in xs]"},
+		{"[x for x in 1..10 if x > 5]", "x", "x", "(1..10)", "(x > 5)", "[x for x in (1..10) if (x > 5)]"},
+		{"[f(y) for y in [1, 2, 3]]", "f(y)", "y", "[1, 2, 3]", "", "[f(y) for y in [1, 2, 3]]"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		comprehension, ok := stmt.Expression.(*ast.ListComprehension)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.ListComprehension. got=%T", stmt.Expression)
+		}
+
+		if comprehension.Element.String() != tt.expectedElement {
+			t.Errorf("comprehension.Element wrong. expected=%q, got=%q", tt.expectedElement, comprehension.Element)
+		}
+
+		testIdentifier(t, comprehension.Var, tt.expectedVar)
+
+		if comprehension.Iterable.String() != tt.expectedIterable {
+			t.Errorf("comprehension.Iterable wrong. expected=%q, got=%q", tt.expectedIterable, comprehension.Iterable)
+		}
+
+		filter := ""
+		if comprehension.Filter != nil {
+			filter = comprehension.Filter.String()
+		}
+		if filter != tt.expectedFilter {
+			t.Errorf("comprehension.Filter wrong. expected=%q, got=%q", tt.expectedFilter, filter)
+		}
+
+		if comprehension.String() != tt.expectedString {
+			t.Errorf("comprehension.String() wrong. expected=%q, got=%q", tt.expectedString, comprehension.String())
+		}
+	}
+}
+
+func TestArrayLiteralIsNotComprehension(t *testing.T) {
+	l := lexer.New("[1, 2, 3]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+}
+
+func TestListComprehensionErrors(t *testing.T) {
+	tests := []string{
+		"[x for 1 in xs]",
+		"[x for x xs]",
+		"[x for x in xs",
+		"[x, y for x in xs]",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestImportStatements(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedPath   string
+		expectedNames  []string
+		expectedString string
+	}{
+		{`import "mathlib";`, "mathlib", nil, `import "mathlib";`},
+		{`import "lib/strings"`, "lib/strings", nil, `import "lib/strings";`},
+		{`import { add, sub } from "mathlib";`, "mathlib", []string{"add", "sub"},
+			`import { add, sub } from "mathlib";`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ImportStatement. got=%T", program.Statements[0])
+		}
+
+		if stmt.Path != tt.expectedPath {
+			t.Errorf("stmt.Path wrong. expected=%q, got=%q", tt.expectedPath, stmt.Path)
+		}
+
+		if tt.expectedNames == nil && stmt.Names != nil {
+			t.Errorf("stmt.Names is not nil. got=%v", stmt.Names)
+		}
+
+		if len(stmt.Names) != len(tt.expectedNames) {
+			t.Fatalf("len(stmt.Names) wrong. expected=%d, got=%d", len(tt.expectedNames), len(stmt.Names))
+		}
+
+		for i, name := range stmt.Names {
+			testIdentifier(t, name, tt.expectedNames[i])
+		}
+
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.expectedString, stmt.String())
+		}
+	}
+}
+
+func TestImportStatementErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"import;", "expected next token to be STRING, got ';' (SEMICOLON) instead"},
+		{`import { add } "mathlib";`, "expected next token to be FROM, got 'mathlib' (STRING) instead"},
+		{`import { add sub } from "mathlib";`, "expected next token to be COMMA, got 'sub' (IDENT) instead"},
+		{`import { 1 } from "mathlib";`, "expected next token to be IDENT, got '1' (INT) instead"},
+		{`import {} from "mathlib";`, "name list is empty"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+
+		if p.Errors()[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expectedError, p.Errors()[0])
+		}
+	}
+}
+
+func TestExportStatements(t *testing.T) {
+	tests := []struct {
+		input               string
+		expectedDeclaration string
+		expectedNames       []string
+		expectedString      string
+	}{
+		{"export let x = 1;", "let x = 1;", nil, "export let x = 1;"},
+		{"export const y = 2", "const y = 2;", nil, "export const y = 2;"},
+		{"export fn f() {}", "let f = fn();", nil, "export let f = fn();"},
+		{"export fn add(a, b) { a + b };", "let add = fn(a, b)(a + b);", nil, "export let add = fn(a, b)(a + b);"},
+		{"export { a, b };", "", []string{"a", "b"}, "export { a, b };"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExportStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExportStatement. got=%T", program.Statements[0])
+		}
+
+		if tt.expectedDeclaration != "" {
+			if _, ok := stmt.Declaration.(*ast.LetStatement); !ok {
+				t.Fatalf("stmt.Declaration is not ast.LetStatement. got=%T", stmt.Declaration)
+			}
+
+			if stmt.Declaration.String() != tt.expectedDeclaration {
+				t.Errorf("stmt.Declaration wrong. expected=%q, got=%q", tt.expectedDeclaration, stmt.Declaration)
+			}
+		} else if stmt.Declaration != nil {
+			t.Errorf("stmt.Declaration is not nil. got=%s", stmt.Declaration)
+		}
+
+		if len(stmt.Names) != len(tt.expectedNames) {
+			t.Fatalf("len(stmt.Names) wrong. expected=%d, got=%d", len(tt.expectedNames), len(stmt.Names))
+		}
+
+		for i, name := range stmt.Names {
+			testIdentifier(t, name, tt.expectedNames[i])
+		}
+
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.expectedString, stmt.String())
+		}
+	}
+}
+
+func TestExportStatementErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"export 5;", "expected declaration or name list after export, got '5' (INT) instead"},
+		{"export;", "expected declaration or name list after export, got ';' (SEMICOLON) instead"},
+		{"export fn () {}", "expected next token to be IDENT, got '(' (LPAREN) instead"},
+		{"export { a b };", "expected next token to be COMMA, got 'b' (IDENT) instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+
+		if p.Errors()[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expectedError, p.Errors()[0])
+		}
+	}
+}
+
+func TestStatementComments(t *testing.T) {
+	input := `// adds two numbers
+// and returns the sum
+let add = fn(a, b) {
+	// inner doc
+	a + b // sum
+};
+let x = add(1, // one
+	2); // call
+x`
+
+	l := lexer.New(input, lexer.WithComments())
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
+	}
+
+	tests := []struct {
+		node             ast.Node
+		expectedLeading  string
+		expectedTrailing string
+	}{
+		{program.Statements[0], "adds two numbers\nand returns the sum", ""},
+		{program.Statements[1], "", "call"},
+		{program.Statements[2], "", ""},
+	}
+
+	fn := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	tests = append(tests, struct {
+		node             ast.Node
+		expectedLeading  string
+		expectedTrailing string
+	}{fn.Body.Statements[0], "inner doc", "sum"})
+
+	for i, tt := range tests {
+		comments := ast.CommentsOf(tt.node)
+		if comments == nil {
+			t.Fatalf("tests[%d] - %T carries no comments", i, tt.node)
+		}
+
+		if comments.LeadingComment != tt.expectedLeading {
+			t.Errorf("tests[%d] - LeadingComment wrong. expected=%q, got=%q", i, tt.expectedLeading, comments.LeadingComment)
+		}
+
+		if comments.TrailingComment != tt.expectedTrailing {
+			t.Errorf("tests[%d] - TrailingComment wrong. expected=%q, got=%q", i, tt.expectedTrailing, comments.TrailingComment)
+		}
+	}
+}
+
+func TestCommentsDoNotAffectParsing(t *testing.T) {
+	input := `let f = (a, // first
+	b) => a + b;
+let h = { // hash
+	"k": 1 };`
+
+	withComments := New(lexer.New(input, lexer.WithComments()))
+	a := withComments.ParseProgram()
+	checkParserErrors(t, withComments)
+
+	withoutComments := New(lexer.New(input))
+	b := withoutComments.ParseProgram()
+	checkParserErrors(t, withoutComments)
+
+	if !ast.Equal(a, b) {
+		t.Errorf("programs differ:\n%s\n%s", a.String(), b.String())
+	}
+}
+
+func TestWarnBuiltinShadow(t *testing.T) {
+	tests := []struct {
+		input            string
+		enabled          bool
+		expectedWarnings []string
+	}{
+		{"let len = 5;", true, []string{"len shadows a builtin"}},
+		{"let length = 5;", true, []string{}},
+		{"let len = 5;", false, []string{}},
+		{"let a = 1, puts = 2;", true, []string{"puts shadows a builtin"}},
+		{"fn(first, x) { first }", true, []string{"first shadows a builtin"}},
+		{"export fn push(x) { x }", true, []string{"push shadows a builtin"}},
+		{"let rest = fn(len) { len };", true,
+			[]string{"rest shadows a builtin", "len shadows a builtin"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.WarnBuiltinShadow = tt.enabled
+		p.ParseProgram()
+		checkParserErrors(t, p)
+
+		warnings := p.Warnings()
+		if len(warnings) != len(tt.expectedWarnings) {
+			t.Fatalf("wrong number of warnings for %q. expected=%v, got=%v", tt.input, tt.expectedWarnings, warnings)
+		}
+
+		for i, warning := range warnings {
+			if warning.Message != tt.expectedWarnings[i] {
+				t.Errorf("warnings[%d] wrong for %q. expected=%q, got=%q", i, tt.input, tt.expectedWarnings[i], warning.Message)
+			}
+		}
+	}
+}
+
+func TestWarnBuiltinShadowCustomBuiltins(t *testing.T) {
+	p := New(lexer.New("let len = 1; let say = 2;"))
+	p.WarnBuiltinShadow = true
+	p.Builtins = map[string]bool{"say": true}
+	p.ParseProgram()
+
+	expected := "say shadows a builtin"
+	if len(p.Warnings()) != 1 || p.Warnings()[0].Message != expected {
+		t.Errorf("wrong warnings. expected=%q, got=%v", expected, p.Warnings())
+	}
+}
+
+func TestFunctionLiteralExpressionBody(t *testing.T) {
+	tests := []struct {
+		input      string
+		equivalent string
+	}{
+		{"fn(x) => x", "fn(x) { return x; }"},
+		{"fn(a, b) => a + b * 2", "fn(a, b) { return a + b * 2; }"},
+		{"fn() => f(1)", "fn() { return f(1); }"},
+		{"let add = fn(a, b) => a + b;", "let add = fn(a, b) { return a + b; };"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		q := New(lexer.New(tt.equivalent))
+		expected := q.ParseProgram()
+		checkParserErrors(t, q)
+
+		if !ast.Equal(program, expected) {
+			t.Errorf("%q is not equal to %q:\n%s\n%s", tt.input, tt.equivalent, program, expected)
+		}
+	}
+}
+
+func TestFunctionLiteralBlockBodyUnchanged(t *testing.T) {
+	p := New(lexer.New("fn(x) { x; }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statements. got=%d", len(function.Body.Statements))
+	}
+
+	if _, ok := function.Body.Statements[0].(*ast.ExpressionStatement); !ok {
+		t.Errorf("function body stmt is not ast.ExpressionStatement. got=%T", function.Body.Statements[0])
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	tests := []string{
+		strings.Repeat("(", 100000),
+		strings.Repeat("(", 100000) + "1" + strings.Repeat(")", 100000),
+		strings.Repeat("[", 100000),
+		strings.Repeat("-", 100000) + "1",
+		strings.Repeat("fn() { ", 100000),
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != "expression nesting too deep" {
+			t.Errorf("wrong errors for %.10q... expected=[%q], got=%.3q",
+				input, "expression nesting too deep", errors)
+		}
+	}
+}
+
+func TestMaxDepthConfigurable(t *testing.T) {
+	input := "((((1))))"
+
+	p := New(lexer.New(input))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	p = New(lexer.New(input))
+	p.MaxDepth = 3
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 || p.Errors()[0] != "expression nesting too deep" {
+		t.Errorf("expected nesting error with MaxDepth=3. got=%v", p.Errors())
+	}
+
+	p = New(lexer.New(input))
+	p.MaxDepth = 0
+	p.ParseProgram()
+	checkParserErrors(t, p)
+}
+
+func TestEmptyStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedCount int
+	}{
+		{";;;", 0},
+		{"let x = 5;;", 1},
+		{"if (x) { y; };", 1},
+		{"fn() { ;; x };", 1},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != tt.expectedCount {
+			t.Errorf("%s - expected %d statements, got=%d", tt.input, tt.expectedCount, len(program.Statements))
+		}
+	}
+
+	p := New(lexer.New("let x = 5;;"))
+	program := p.ParseProgram()
+	if _, ok := program.Statements[0].(*ast.LetStatement); !ok {
+		t.Errorf("program.Statements[0] is not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+}
+
+func TestMultiAssignStatement(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedTargets []string
+		expectedValues  []string
+		expectedString  string
+	}{
+		{"a, b = b, a;", []string{"a", "b"}, []string{"b", "a"}, "a, b = b, a;"},
+		{"x, y, z = 1, y + 1, f(x)", []string{"x", "y", "z"}, []string{"1", "(y + 1)", "f(x)"}, "x, y, z = 1, (y + 1), f(x);"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.MultiAssignStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.MultiAssignStatement. got=%T", program.Statements[0])
+		}
+
+		if len(stmt.Targets) != len(tt.expectedTargets) {
+			t.Fatalf("wrong number of targets. expected=%d, got=%d", len(tt.expectedTargets), len(stmt.Targets))
+		}
+
+		for i, target := range tt.expectedTargets {
+			testIdentifier(t, stmt.Targets[i], target)
+		}
+
+		if len(stmt.Values) != len(tt.expectedValues) {
+			t.Fatalf("wrong number of values. expected=%d, got=%d", len(tt.expectedValues), len(stmt.Values))
+		}
+
+		for i, value := range tt.expectedValues {
+			if stmt.Values[i].String() != value {
+				t.Errorf("stmt.Values[%d] wrong. expected=%q, got=%q", i, value, stmt.Values[i].String())
+			}
+		}
+
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.expectedString, stmt.String())
+		}
+	}
+}
+
+func TestMultiAssignStatementCountMismatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a, b = 1;", "assignment mismatch: 2 targets but 1 values"},
+		{"a, b = 1, 2, 3;", "assignment mismatch: 2 targets but 3 values"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%s - expected error %q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestWordLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a and b or not c", "a && b || !c"},
+		{"not a and b", "!a && b"},
+		{"a or b and c", "a || (b && c)"},
+		{"not not x", "!!x"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		expected := New(lexer.New(tt.expected)).ParseProgram()
+		if !ast.Equal(program, expected) {
+			t.Errorf("%q is not parsed like %q. got=%q", tt.input, tt.expected, program.String())
+		}
+
+		if program.String() != expected.String() {
+			t.Errorf("String() wrong. expected=%q, got=%q", expected.String(), program.String())
+		}
+	}
+}
+
+func TestMaxElements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4]", "too many elements in list, the limit is 3"},
+		{"f(1, 2, 3, 4)", "too many elements in list, the limit is 3"},
+		{`{"a": 1, "b": 2, "c": 3, "d": 4}`, "too many pairs in hash literal, the limit is 3"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.MaxElements = 3
+		p.ParseProgram()
+
+		errors := p.DetailedErrors()
+		if len(errors) != 1 || errors[0].Message != tt.expected || errors[0].Kind != TooManyElements {
+			t.Errorf("%s - expected error %q, got=%v", tt.input, tt.expected, errors)
+		}
+
+		p = New(lexer.New(tt.input))
+		p.MaxElements = 4
+		p.ParseProgram()
+		checkParserErrors(t, p)
+	}
+}
+
+func TestMaxElementsUnlimitedByDefault(t *testing.T) {
+	input := "[" + strings.Repeat("0, ", 10000) + "0]"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	array := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.ArrayLiteral)
+	if len(array.Elements) != 10001 {
+		t.Errorf("wrong number of elements. expected=10001, got=%d", len(array.Elements))
+	}
+}
+
+func TestStructLiteral(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedFields map[string]int64
+		expectedNames  []string
+		expectedString string
+	}{
+		{"struct { x: 1, y: 2 }", map[string]int64{"x": 1, "y": 2}, []string{"x", "y"}, "struct { x: 1, y: 2 }"},
+		{"struct {}", map[string]int64{}, []string{}, "struct {}"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.StructLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.StructLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(literal.Fields) != len(tt.expectedNames) {
+			t.Fatalf("literal.Fields has wrong length. expected=%d, got=%d", len(tt.expectedNames), len(literal.Fields))
+		}
+
+		for i, field := range literal.Fields {
+			if field.Name.Value != tt.expectedNames[i] {
+				t.Errorf("field %d name wrong. expected=%q, got=%q", i, tt.expectedNames[i], field.Name.Value)
+			}
+			testIntegerLiteral(t, field.Value, tt.expectedFields[field.Name.Value])
+		}
+
+		if literal.String() != tt.expectedString {
+			t.Errorf("literal.String() wrong. expected=%q, got=%q", tt.expectedString, literal.String())
+		}
+	}
+}
+
+func TestStructLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct { x: 1, x: 2 }", "duplicate field x in struct literal"},
+		{`struct { "x": 1 }`, "expected next token to be IDENT, got 'x' (STRING) instead"},
+		{"struct { x 1 }", "expected next token to be COLON, got '1' (INT) instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s - expected first error %q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestNamedFunctionLiteral(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedName   string
+		expectedString string
+	}{
+		{"let f = fn fact(n) { fact(n - 1) };", "fact", "let f = fn fact(n)fact((n - 1));"},
+		{"fn loop() { loop() }", "loop", "fn loop()loop()"},
+		{"let f = fn(n) { n };", "", "let f = fn(n)n;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var function *ast.FunctionLiteral
+		switch stmt := program.Statements[0].(type) {
+		case *ast.LetStatement:
+			function, _ = stmt.Value.(*ast.FunctionLiteral)
+		case *ast.ExpressionStatement:
+			function, _ = stmt.Expression.(*ast.FunctionLiteral)
+		}
+
+		if function == nil {
+			t.Fatalf("%s - no *ast.FunctionLiteral found. got=%T", tt.input, program.Statements[0])
+		}
+
+		if tt.expectedName == "" {
+			if function.Name != nil {
+				t.Errorf("%s - function.Name is not nil. got=%q", tt.input, function.Name.Value)
+			}
+		} else {
+			testIdentifier(t, function.Name, tt.expectedName)
+		}
+
+		if program.String() != tt.expectedString {
+			t.Errorf("%s - String() wrong. expected=%q, got=%q", tt.input, tt.expectedString, program.String())
+		}
+	}
+}
+
+func TestFloatLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"1e10", 1e10},
+		{"2.5e-3", 2.5e-3},
+		{"6.022E23", 6.022e23},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() not %q. got=%q", tt.input, literal.String())
+		}
+	}
+}
+
+func TestFloatLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1e", `could not parse "1e" as float`},
+		{"1e+;", `could not parse "1e+" as float`},
+		{"1e400", "float literal out of range: 1e400"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.DetailedErrors()
+		if len(errors) != 1 || errors[0].Message != tt.expected || errors[0].Kind != InvalidFloat {
+			t.Errorf("%s - expected error %q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestNumberLiteralSuffixes(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedValue  interface{}
+		expectedSuffix string
+	}{
+		{"5i", int64(5), "i"},
+		{"10u", int64(10), "u"},
+		{"7l", int64(7), "l"},
+		{"5", int64(5), ""},
+		{"3.0f", 3.0, "f"},
+		{"1e5f", 1e5, "f"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		var value interface{}
+		var suffix string
+		switch literal := stmt.Expression.(type) {
+		case *ast.IntegerLiteral:
+			value, suffix = literal.Value, literal.Suffix
+		case *ast.FloatLiteral:
+			value, suffix = literal.Value, literal.Suffix
+		default:
+			t.Fatalf("%q: exp not a number literal. got=%T", tt.input, stmt.Expression)
+		}
+
+		if value != tt.expectedValue || suffix != tt.expectedSuffix {
+			t.Errorf("%q: wrong literal. expected=%v%s, got=%v%s",
+				tt.input, tt.expectedValue, tt.expectedSuffix, value, suffix)
+		}
+
+		if stmt.Expression.String() != tt.input {
+			t.Errorf("%q: String() wrong. got=%q", tt.input, stmt.Expression.String())
+		}
+	}
+}
+
+func TestNumberLiteralSuffixErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		kind     ErrorKind
+	}{
+		{"5z", `unknown suffix "z" on number literal 5z`, InvalidInteger},
+		{"5ul", `unknown suffix "ul" on number literal 5ul`, InvalidInteger},
+		{"1.5x", `unknown suffix "x" on number literal 1.5x`, InvalidFloat},
+		{"5f", `suffix "f" does not fit number literal 5f`, InvalidInteger},
+		{"1.5i", `suffix "i" does not fit number literal 1.5i`, InvalidFloat},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.DetailedErrors()
+		if len(errors) != 1 || errors[0].Message != tt.expected || errors[0].Kind != tt.kind {
+			t.Errorf("%s - expected error %q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestConstantFoldingSkipsSuffixedLiterals(t *testing.T) {
+	p := New(lexer.New("1u - 2u"))
+	p.FoldConstants = true
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "(1u - 2u)" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match x { 1 => "one", 2 | 3 => "low", _ => "other" }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	match, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MatchExpression. got=%T", stmt.Expression)
+	}
+
+	testIdentifier(t, match.Subject, "x")
+
+	expected := []struct {
+		patterns []interface{}
+		result   string
+	}{
+		{[]interface{}{1}, "one"},
+		{[]interface{}{2, 3}, "low"},
+		{[]interface{}{"_"}, "other"},
+	}
+
+	if len(match.Arms) != len(expected) {
+		t.Fatalf("match.Arms has wrong length. expected=%d, got=%d", len(expected), len(match.Arms))
+	}
+
+	for i, arm := range match.Arms {
+		if len(arm.Patterns) != len(expected[i].patterns) {
+			t.Fatalf("arm %d has wrong number of patterns. expected=%d, got=%d", i, len(expected[i].patterns), len(arm.Patterns))
+		}
+
+		for j, pattern := range expected[i].patterns {
+			testLiteralExpression(t, arm.Patterns[j], pattern)
+		}
+
+		result, ok := arm.Result.(*ast.StringLiteral)
+		if !ok || result.Value != expected[i].result {
+			t.Errorf("arm %d result wrong. expected=%q, got=%s", i, expected[i].result, arm.Result)
+		}
+	}
+
+	if match.String() != "match x { 1 => one, 2 | 3 => low, _ => other }" {
+		t.Errorf("match.String() wrong. got=%q", match.String())
+	}
+}
+
+func TestMatchExpressionAcrossLines(t *testing.T) {
+	input := "let y = match x {\n  1 => a,\n  _ => b\n}\ny"
+
+	p := New(lexer.New(input, lexer.WithNewlines()))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	if program.Statements[0].String() != "let y = match x { 1 => a, _ => b };" {
+		t.Errorf("String() wrong. got=%q", program.Statements[0].String())
+	}
+}
+
+func TestMatchExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match x {}", "match expression has no arms"},
+		{"match x { 1 2 }", "expected next token to be ARROW, got '2' (INT) instead"},
+		{"match x { 1 => 2 3 => 4 }", "expected next token to be COMMA, got '3' (INT) instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s - expected first error %q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestMatchExpressionDestructuring(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedPatterns []string
+		expectedBindings [][]string
+	}{
+		{"match point { [x, y] => x + y, _ => 0 }", []string{"[x, y]", "_"}, [][]string{{"x", "y"}, {}}},
+		{"match p { {name, age} => name, _ => 0 }", []string{"{name, age}", "_"}, [][]string{{"name", "age"}, {}}},
+		{
+			"match v { 0 => a, [head, ...tail] => tail, {x} => x, _ => b }",
+			[]string{"0", "[head, ...tail]", "{x}", "_"},
+			[][]string{{}, {"head", "tail"}, {"x"}, {}},
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		match, ok := stmt.Expression.(*ast.MatchExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.MatchExpression. got=%T", stmt.Expression)
+		}
+
+		if len(match.Arms) != len(tt.expectedPatterns) {
+			t.Fatalf("%s - match.Arms has wrong length. expected=%d, got=%d", tt.input, len(tt.expectedPatterns), len(match.Arms))
+		}
+
+		for i, arm := range match.Arms {
+			if arm.Patterns[0].String() != tt.expectedPatterns[i] {
+				t.Errorf("%s - arm %d pattern wrong. expected=%q, got=%q", tt.input, i, tt.expectedPatterns[i], arm.Patterns[0].String())
+			}
+
+			bindings := []string{}
+			for _, binding := range arm.Bindings() {
+				bindings = append(bindings, binding.Value)
+			}
+
+			if strings.Join(bindings, ",") != strings.Join(tt.expectedBindings[i], ",") {
+				t.Errorf("%s - arm %d bindings wrong. expected=%v, got=%v", tt.input, i, tt.expectedBindings[i], bindings)
+			}
+		}
+	}
+
+	p := New(lexer.New("match point { [x, y] => x + y, _ => 0 }"))
+	program := p.ParseProgram()
+	match := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	if _, ok := match.Arms[0].Patterns[0].(*ast.ArrayPattern); !ok {
+		t.Errorf("pattern is not *ast.ArrayPattern. got=%T", match.Arms[0].Patterns[0])
+	}
+	testInfixExpression(t, match.Arms[0].Result, "x", "+", "y")
+}
+
+func TestMatchExpressionInvalidDestructuring(t *testing.T) {
+	p := New(lexer.New("match v { [1, 2] => a }"))
+	p.ParseProgram()
+
+	expected := "expected next token to be IDENT, got '1' (INT) instead"
+	if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+		t.Errorf("expected first error %q, got=%q", expected, p.Errors())
+	}
+}
+
+func TestBlocklessIfExpression(t *testing.T) {
+	tests := []struct {
+		input               string
+		expectedConsequence string
+		expectedAlternative string
+	}{
+		{"if (x) return 1;", "return 1;", ""},
+		{"if (x) y; else z;", "y", "z"},
+		{"if (x) y else z", "y", "z"},
+		{"if (x) { y } else z;", "y", "z"},
+		{"if (x) y; else if (z) w;", "y", "ifz w"},
+		{"if (x) { let a = 1; a } else { b; c }", "let a = 1;a", "bc"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%s - program.Statements does not contain 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		expression, ok := stmt.Expression.(*ast.IfExpression)
+		if !ok {
+			t.Fatalf("%s - exp not *ast.IfExpression. got=%T", tt.input, stmt.Expression)
+		}
+
+		testIdentifier(t, expression.Condition, "x")
+
+		if expression.Consequence.String() != tt.expectedConsequence {
+			t.Errorf("%s - consequence wrong. expected=%q, got=%q", tt.input, tt.expectedConsequence, expression.Consequence.String())
+		}
+
+		alternative := ""
+		if expression.Alternative != nil {
+			alternative = expression.Alternative.String()
+		}
+
+		if alternative != tt.expectedAlternative {
+			t.Errorf("%s - alternative wrong. expected=%q, got=%q", tt.input, tt.expectedAlternative, alternative)
+		}
+	}
+}
+
+func TestBlocklessIfFollowedByStatement(t *testing.T) {
+	input := "if (done) return result; let x = 1;"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	if _, ok := program.Statements[1].(*ast.LetStatement); !ok {
+		t.Errorf("program.Statements[1] is not *ast.LetStatement. got=%T", program.Statements[1])
+	}
+}
+
+func TestNonNullAssertion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x!", "(x!)"},
+		{"a.b!", "((a.b)!)"},
+		{"a.b!.c", "(((a.b)!).c)"},
+		{"f(x)![0]", "((f(x)!)[0])"},
+		{"!x", "(!x)"},
+		{"!x!", "(!(x!))"},
+		{"-a! + b", "((-(a!)) + b)"},
+		{"a != b", "(a != b)"},
+		{"let a = b\n!c", "let a = b;(!c)"},
+		{"x\n!y", "x(!y)"},
+		{"a ! b", "a(!b)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("%s - String() wrong. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("let x = y!\n-1", lexer.WithNewlines()))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 || program.String() != "let x = (y!);(-1)" {
+		t.Errorf("newline after postfix '!' does not end the statement. got=%q", program.String())
+	}
+
+	p = New(lexer.New("a.b!"))
+	program = p.ParseProgram()
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	assertion, ok := stmt.Expression.(*ast.NonNullAssertion)
+	if !ok {
+		t.Fatalf("exp not *ast.NonNullAssertion. got=%T", stmt.Expression)
+	}
+
+	if _, ok := assertion.Left.(*ast.DotExpression); !ok {
+		t.Errorf("assertion.Left is not *ast.DotExpression. got=%T", assertion.Left)
+	}
+
+	p = New(lexer.New("!x"))
+	program = p.ParseProgram()
+	stmt = program.Statements[0].(*ast.ExpressionStatement)
+	prefix, ok := stmt.Expression.(*ast.PrefixExpression)
+	if !ok || prefix.Operator != "!" {
+		t.Errorf("exp not a prefix '!'. got=%T", stmt.Expression)
+	}
+}
+
+func TestForInExpression(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedIndex    string
+		expectedVar      string
+		expectedIterable string
+		expectedString   string
+	}{
+		{"for (x in [1, 2, 3]) {}", "", "x", "[1, 2, 3]", "for (x in [1, 2, 3]) "},
+		{"for (i, x in arr) { puts(i, x) }", "i", "x", "arr", "for (i, x in arr) puts(i, x)"},
+		{"for (k in keys(h)) { k }", "", "k", "keys(h)", "for (k in keys(h)) k"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		expression, ok := stmt.Expression.(*ast.ForInExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.ForInExpression. got=%T", stmt.Expression)
+		}
+
+		if tt.expectedIndex == "" {
+			if expression.Index != nil {
+				t.Errorf("%s - expression.Index is not nil. got=%q", tt.input, expression.Index.Value)
+			}
+		} else {
+			testIdentifier(t, expression.Index, tt.expectedIndex)
+		}
+
+		testIdentifier(t, expression.Var, tt.expectedVar)
+
+		if expression.Iterable.String() != tt.expectedIterable {
+			t.Errorf("%s - iterable wrong. expected=%q, got=%q", tt.input, tt.expectedIterable, expression.Iterable.String())
+		}
+
+		if expression.String() != tt.expectedString {
+			t.Errorf("%s - String() wrong. expected=%q, got=%q", tt.input, tt.expectedString, expression.String())
+		}
+	}
+}
+
+func TestForInExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for x in xs {}", "expected next token to be LPAREN, got 'x' (IDENT) instead"},
+		{"for (x of xs) {}", "expected next token to be IN, got 'of' (IDENT) instead"},
+		{"for (i, 1 in xs) {}", "expected next token to be IDENT, got '1' (INT) instead"},
+		{"for (x in xs) x", "expected next token to be LBRACE, got 'x' (IDENT) instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s - expected first error %q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestScopedAssignStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedScope ast.Scope
+		expectedName  string
+		expectedValue interface{}
+	}{
+		{"outer x = 5;", ast.Outer, "x", 5},
+		{"global y = 1;", ast.Global, "y", 1},
+		{"global done = true", ast.Global, "done", true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ScopedAssignStatement)
+		if !ok {
+			t.Fatalf("stmt is not ast.ScopedAssignStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Scope != tt.expectedScope {
+			t.Errorf("stmt.Scope wrong. expected=%d, got=%d", tt.expectedScope, stmt.Scope)
+		}
+		if stmt.Token.Type != token.OUTER && stmt.Token.Type != token.GLOBAL {
+			t.Errorf("stmt.Token.Type wrong. got=%q", stmt.Token.Type)
+		}
+		testIdentifier(t, stmt.Target, tt.expectedName)
+		testLiteralExpression(t, stmt.Value, tt.expectedValue)
+
+		if program.String() != tt.input && program.String() != tt.input+";" {
+			t.Errorf("program.String() wrong. got=%q", program.String())
+		}
+	}
+}
+
+func TestScopedAssignStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"outer 5;", "outer must be followed by an assignment to a name, got 5"},
+		{"global x[0] = 1;", "expected next token to be ASSIGN, got '[' (LBRACKET) instead"},
+		{"outer x;", "expected next token to be ASSIGN, got ';' (SEMICOLON) instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%q: wrong errors. expected first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestScopeModifiersAsNames(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"outer: while (x) { break outer; }", "outer: whilex break outer;"},
+		{"let outer = 1; outer = outer + 1;", "let outer = 1;outer = (outer + 1);"},
+		{"global(1)", "global(1)"},
+		{"outer\nx = 1", "outerx = 1;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		for _, stmt := range program.Statements {
+			if _, ok := stmt.(*ast.ScopedAssignStatement); ok {
+				t.Errorf("%q: unexpected scoped assignment %q", tt.input, stmt.String())
+			}
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("%q: program.String() wrong. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestYieldStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue interface{}
+		expected      string
+	}{
+		{"yield 5;", 5, "yield 5;"},
+		{"yield x", "x", "yield x;"},
+		{"yield;", nil, "yield;"},
+		{"yield", nil, "yield;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.YieldStatement)
+		if !ok {
+			t.Fatalf("stmt is not ast.YieldStatement. got=%T", program.Statements[0])
+		}
+		if tt.expectedValue == nil {
+			if stmt.Value != nil {
+				t.Errorf("stmt.Value is not nil. got=%s", stmt.Value)
+			}
+		} else {
+			testLiteralExpression(t, stmt.Value, tt.expectedValue)
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestYieldInFunctionBody(t *testing.T) {
+	input := `let count = fn(n) { let i = 0; while (i < n) { yield i; i = i + 1; } yield };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	function := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if len(function.Body.Statements) != 3 {
+		t.Fatalf("function body has wrong number of statements. got=%d", len(function.Body.Statements))
+	}
+
+	loop := function.Body.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.WhileExpression)
+	inner, ok := loop.Body.Statements[0].(*ast.YieldStatement)
+	if !ok {
+		t.Fatalf("loop body statement is not ast.YieldStatement. got=%T", loop.Body.Statements[0])
+	}
+	testIdentifier(t, inner.Value, "i")
+
+	last, ok := function.Body.Statements[2].(*ast.YieldStatement)
+	if !ok {
+		t.Fatalf("last statement is not ast.YieldStatement. got=%T", function.Body.Statements[2])
+	}
+	if last.Value != nil {
+		t.Errorf("last.Value is not nil. got=%s", last.Value)
+	}
+}
+
+func TestDecoratedStatements(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedDecorators []string
+		expected           string
+	}{
+		{"@memoize fn fib(n) { n }", []string{"memoize"}, "@memoize\nlet fib = fn(n)n;"},
+		{"@retry(3, delay: 10) fn get(url) { url }", []string{"retry(3, delay: 10)"}, "@retry(3, delay: 10)\nlet get = fn(url)url;"},
+		{"@trace\n@cache()\nlet f = fn() { 1 };", []string{"trace", "cache()"}, "@trace\n@cache()\nlet f = fn()1;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.DecoratedStatement)
+		if !ok {
+			t.Fatalf("stmt is not ast.DecoratedStatement. got=%T", program.Statements[0])
+		}
+
+		if len(stmt.Decorators) != len(tt.expectedDecorators) {
+			t.Fatalf("%q: wrong number of decorators. expected=%d, got=%d", tt.input, len(tt.expectedDecorators), len(stmt.Decorators))
+		}
+		for i, decorator := range stmt.Decorators {
+			if decorator.Expression.String() != tt.expectedDecorators[i] {
+				t.Errorf("%q: decorator %d wrong. expected=%q, got=%q", tt.input, i, tt.expectedDecorators[i], decorator.Expression.String())
+			}
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestDecoratorErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"@memoize x + 1;", "decorator must be followed by a function declaration, got x"},
+		{"@memoize let x = 5;", "decorator must be followed by a function declaration, got let"},
+		{"@memoize fn() { 1 }", "decorator must be followed by a function declaration, got fn"},
+		{"@5 fn f() {}", "expected next token to be IDENT, got '5' (INT) instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%q: wrong errors. expected first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+
+	p := New(lexer.New("@memoize fn f(1) {}"))
+	p.ParseProgram()
+
+	for _, msg := range p.Errors() {
+		if strings.HasPrefix(msg, "decorator must be followed") {
+			t.Errorf("failed declaration also reported as a wrong one: %q", p.Errors())
+		}
+	}
+}
+
+func TestDecoratedFunctionDeclaresName(t *testing.T) {
+	p := New(lexer.New("@memo fn h() { 1 }"))
+	decorated := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	p = New(lexer.New("export fn h() { 1 }"))
+	exported := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	declaration := decorated.Statements[0].(*ast.DecoratedStatement).Declaration
+	if _, ok := declaration.(*ast.LetStatement); !ok {
+		t.Fatalf("declaration is not *ast.LetStatement. got=%T", declaration)
+	}
+
+	if !ast.Equal(declaration, exported.Statements[0].(*ast.ExportStatement).Declaration) {
+		t.Errorf("decorated and exported declarations differ. got=%q and %q", declaration, exported)
+	}
+}
+
+func TestCustomTerminators(t *testing.T) {
+	p := New(lexer.New("let x = 1 @ let y = x @@ return y"))
+	p.Terminators = map[token.TokenType]bool{token.AT: true}
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := []string{"let x = 1;", "let y = x;", "return y;"}
+	if len(program.Statements) != len(expected) {
+		t.Fatalf("program.Statements has wrong length. expected=%d, got=%d: %q",
+			len(expected), len(program.Statements), program.String())
+	}
+	for i, stmt := range program.Statements {
+		if stmt.String() != expected[i] {
+			t.Errorf("statement %d wrong. expected=%q, got=%q", i, expected[i], stmt.String())
+		}
+	}
+}
+
+func TestNewlineOnlyTerminators(t *testing.T) {
+	input := "let x = 1\nx;"
+
+	p := New(lexer.New(input, lexer.WithNewlines()))
+	p.Terminators = map[token.TokenType]bool{}
+	p.ParseProgram()
+
+	expected := "no prefix parse function for ';' (SEMICOLON) found"
+	if len(p.Errors()) != 1 || p.Errors()[0] != expected {
+		t.Errorf("errors wrong. expected=%q, got=%q", expected, p.Errors())
+	}
+}
+
+func TestNewlinesTerminateWithCustomTerminators(t *testing.T) {
+	p := New(lexer.New("let x = 1\nlet y = x @ y", lexer.WithNewlines()))
+	p.Terminators = map[token.TokenType]bool{token.AT: true}
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Errorf("newlines did not end statements. got=%q", program.String())
+	}
+}
+
+func TestDefaultTerminators(t *testing.T) {
+	p := New(lexer.New("let x = 1;; x\ny"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(p.Terminators) != 1 || !p.Terminators[token.SEMICOLON] || len(program.Statements) != 3 {
+		t.Errorf("default terminators changed. statements=%q", program.String())
+	}
+}
+
+func TestTypeQueryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"typeof x", "(typeof x)"},
+		{"typeof x == \"INTEGER\"", "((typeof x) == INTEGER)"},
+		{"typeof -x + 1", "((typeof (-x)) + 1)"},
+		{"x is Int", "(x is Int)"},
+		{"x is Int == true", "((x is Int) == true)"},
+		{"a == b is Bool", "(a == (b is Bool))"},
+		{"x + 1 is Int", "((x + 1) is Int)"},
+		{"x is Int && y is Str", "((x is Int) && (y is Str))"},
+		{"typeof x is Str", "((typeof x) is Str)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestTypeCheckExpression(t *testing.T) {
+	p := New(lexer.New("x is Int"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	check, ok := stmt.Expression.(*ast.TypeCheckExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.TypeCheckExpression. got=%T", stmt.Expression)
+	}
+	testIdentifier(t, check.Value, "x")
+	testIdentifier(t, check.TypeName, "Int")
+
+	p = New(lexer.New("x is 5"))
+	p.ParseProgram()
+
+	expected := "expected a type name after is, got 5"
+	if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+		t.Errorf("errors wrong. expected first=%q, got=%q", expected, p.Errors())
+	}
+}
+
+func TestParsingWithCustomKeywords(t *testing.T) {
+	keywords := map[string]token.TokenType{
+		"sei":   token.LET,
+		"wenn":  token.IF,
+		"sonst": token.ELSE,
+		"fn":    token.FUNCTION,
+		"wahr":  token.TRUE,
+	}
+	input := "sei max = fn(a, b) { wenn (a > b) { a } sonst { b } }; sei if = wahr;"
+
+	p := New(lexer.New(input, lexer.WithKeywords(keywords)))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := "sei max = fn(a, b)if(a > b) aelse b;sei if = wahr;"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestUnclosedGroupedExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+		line     int
+		column   int
+	}{
+		{"(1 + 2", []string{"unclosed '(' opened at 1:1"}, 1, 1},
+		{"((1)", []string{"unclosed '(' opened at 1:1"}, 1, 1},
+		{"(1, 2", []string{"unclosed '(' opened at 1:1"}, 1, 1},
+		{"let x = (1 + 2;\nx", []string{"unclosed '(' opened at 1:9"}, 1, 9},
+		{"(1 2) + 3", []string{"expected next token to be RPAREN, got '2' (INT) instead"}, 1, 4},
+		{"(1 + )", []string{"no prefix parse function for ')' (RPAREN) found"}, 1, 6},
+		{"(1 + 2)", nil, 0, 0},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("%q: wrong errors. expected=%q, got=%q", tt.input, tt.expected, errors)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errors[i] != msg {
+				t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, msg, errors[i])
+			}
+		}
+		if len(tt.expected) == 0 {
+			continue
+		}
+
+		detailed := p.DetailedErrors()[0]
+		if detailed.Line != tt.line || detailed.Column != tt.column {
+			t.Errorf("%q: wrong position. expected=%d:%d, got=%d:%d",
+				tt.input, tt.line, tt.column, detailed.Line, detailed.Column)
+		}
+	}
+}
+
+// TestParseCrashers holds inputs found by FuzzParse that used to panic.
+func TestParseCrashers(t *testing.T) {
+	tests := []string{
+		// a postfix '!' after a failed call saw a nil left side
+		"0|>0(# !0",
+		// the pipe error message rendered a prefix expression without operand
+		"#0|>!AA(000000008000000",
+		// the invalid assignment target error rendered the same
+		"(!) = 2",
+		// String() of expressions missing a part that failed to parse
+		"!;",
+		"-",
+		"let x = !;",
+		"0*",
+		"0..",
+		`{"":#}`,
+		"A,A=0,",
+		"(0,)",
+		"#if",
+		"struct { x: #, y: 1 }",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		_ = program.String()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected errors", input)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []string{
+		"let x = 5; let y = x * (2 + 3);",
+		"let add = fn(a, b) { return a + b; }; add(1, 2);",
+		`let h = {"a": [1, 2, 3][0], true: fn(x) { x }}; h["a"];`,
+		"if (x < y) { x } else { y }",
+		"while (i < 10) { i = i + 1; break; }",
+		"for (i, x in xs) { puts(i, x) }",
+		"let [head, ...tail] = 1..10; tail[1:2:1];",
+		"match x { 1 | 2 => a, _ => b }",
+		"try { risky() } catch (e) { e }",
+		"xs |> map(fn(x) { x * 2 }) |> sum",
+		`import { a, b } from "lib"; export fn f() {}`,
+		"@memoize fn fib(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }",
+		"interface Shape { fn area(): Float }",
+		"let s = <<END\ntext\nEND\n",
+		`b"\x00" <> "a" <> x is Int`,
+		"let p = struct { x: 1, y: -2 }; p.x",
+		"let q = struct { a: [1, 2], b: { \"k\": struct { c: f(x) } } }; q.b",
+		"unless (a ?? b) { typeof x } do { i = i - 1 } while (i > 0)",
+		"let m = macro(a) { quote(a) }; [x * 2 for x in xs if x > 1]; h?.[k]",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		_ = program.String()
+	})
+}
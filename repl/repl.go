@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"strings"
 )
 
 const PROMPT = ">> "
@@ -24,6 +26,10 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
+		if handleCommand(out, line) {
+			continue
+		}
+
 		lexer := lexer.New(line)
 		parser := parser.New(lexer)
 
@@ -41,6 +47,50 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
+const (
+	AST_COMMAND    = ":ast "
+	TOKENS_COMMAND = ":tokens "
+)
+
+// handleCommand runs the REPL meta command in line, if any, and reports
+// whether line was a command.
+func handleCommand(out io.Writer, line string) bool {
+	switch {
+	case strings.HasPrefix(line, AST_COMMAND):
+		parser := parser.New(lexer.New(strings.TrimPrefix(line, AST_COMMAND)))
+		program := parser.ParseProgram()
+		if len(parser.Errors()) != 0 {
+			printParserErrors(out, parser.Errors())
+			return true
+		}
+
+		printNode(out, program, 0)
+		return true
+
+	case strings.HasPrefix(line, TOKENS_COMMAND):
+		for _, tok := range lexer.Tokenize(strings.TrimPrefix(line, TOKENS_COMMAND)) {
+			fmt.Fprintf(out, "%s %q\n", tok.Type, tok.Literal)
+		}
+		return true
+	}
+
+	return false
+}
+
+func printNode(out io.Writer, node ast.Node, depth int) {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	fmt.Fprintf(out, "%s%s %s\n", strings.Repeat("  ", depth), name, node.String())
+
+	ast.Walk(node, func(child ast.Node) bool {
+		if child == node {
+			return true
+		}
+
+		printNode(out, child, depth+1)
+		return false
+	})
+}
+
 const MONKEY_FACE = `            __,__
    .--.  .-"     "-.  .--.
   / .. \/  .-. .-.  \/ .. \
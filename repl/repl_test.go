@@ -0,0 +1,68 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStartAstCommand(t *testing.T) {
+	in := strings.NewReader(":ast let x = 1 + 2;\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	expected := PROMPT +
+		"Program let x = (1 + 2);\n" +
+		"  LetStatement let x = (1 + 2);\n" +
+		"    Identifier x\n" +
+		"    InfixExpression (1 + 2)\n" +
+		"      IntegerLiteral 1\n" +
+		"      IntegerLiteral 2\n" +
+		PROMPT
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestStartTokensCommand(t *testing.T) {
+	in := strings.NewReader(":tokens x + 1\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	expected := PROMPT +
+		"IDENT \"x\"\n" +
+		"PLUS \"+\"\n" +
+		"INT \"1\"\n" +
+		"EOF \"\"\n" +
+		PROMPT
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestStartEvaluatesOtherLines(t *testing.T) {
+	in := strings.NewReader("let a = 5;\na * 2\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	expected := PROMPT + PROMPT + "10\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestStartAstCommandParseError(t *testing.T) {
+	in := strings.NewReader(":ast let = 1;\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	if !strings.Contains(out.String(), "parser errors:") {
+		t.Errorf("expected parser errors in output. got=%q", out.String())
+	}
+}
@@ -5,6 +5,8 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1-based line of the first char
+	Column  int // 1-based byte column of the first char
 }
 
 const (
@@ -14,6 +16,7 @@ const (
 	// identifiers + literals
 	IDENT = "IDENT" // add, foobar, x, y
 	INT   = "INT"   // 12345
+	FLOAT = "FLOAT" // 1.5, 2.5e-3
 
 	// operators
 	ASSIGN   = "="
@@ -28,10 +31,28 @@ const (
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	AND          = "&&"
+	OR           = "||"
+	NULLCOALESCE = "??"
+
+	DOT         = "."
+	ELLIPSIS    = "..."
+	PIPE        = "|>"
+	DOTDOT      = ".."
+	DOTDOTLT    = "..<"
+	ARROW       = "=>"
+	QUESTIONDOT = "?."
+	BAR         = "|"
+	CONCAT      = "<>"
+
 	// delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	NEWLINE   = "NEWLINE"
+	INDENT    = "INDENT"
+	DEDENT    = "DEDENT"
 	COLON     = ":"
+	AT        = "@"
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -41,25 +62,194 @@ const (
 	RBRACKET = "]"
 
 	// keywords
-	FUNCTION = "FUNCTION"
-	LET      = "LET"
-	TRUE     = "TRUE"
-	FALSE    = "FALSE"
-	IF       = "IF"
-	ELSE     = "ELSE"
-	RETURN   = "RETURN"
+	FUNCTION  = "FUNCTION"
+	LET       = "LET"
+	CONST     = "CONST"
+	TRUE      = "TRUE"
+	FALSE     = "FALSE"
+	IF        = "IF"
+	UNLESS    = "UNLESS"
+	ELSE      = "ELSE"
+	RETURN    = "RETURN"
+	WHILE     = "WHILE"
+	FOR       = "FOR"
+	IN        = "IN"
+	DO        = "DO"
+	BREAK     = "BREAK"
+	CONTINUE  = "CONTINUE"
+	MACRO     = "MACRO"
+	IMPORT    = "IMPORT"
+	FROM      = "FROM"
+	EXPORT    = "EXPORT"
+	TRY       = "TRY"
+	CATCH     = "CATCH"
+	STRUCT    = "STRUCT"
+	MATCH     = "MATCH"
+	AND_KW    = "AND_KW"
+	OR_KW     = "OR_KW"
+	NOT_KW    = "NOT_KW"
+	PRINT     = "PRINT"
+	INTERFACE = "INTERFACE"
+	THEN      = "THEN"
+	OUTER     = "OUTER"
+	GLOBAL    = "GLOBAL"
+	YIELD     = "YIELD"
+	TYPEOF    = "TYPEOF"
+	IS        = "IS"
 
 	STRING = "STRING"
+	BYTES  = "BYTES"
+	REGEX  = "REGEX"
+
+	COMMENT = "COMMENT"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":        FUNCTION,
+	"let":       LET,
+	"const":     CONST,
+	"true":      TRUE,
+	"false":     FALSE,
+	"if":        IF,
+	"unless":    UNLESS,
+	"else":      ELSE,
+	"return":    RETURN,
+	"while":     WHILE,
+	"for":       FOR,
+	"in":        IN,
+	"do":        DO,
+	"break":     BREAK,
+	"continue":  CONTINUE,
+	"macro":     MACRO,
+	"import":    IMPORT,
+	"from":      FROM,
+	"export":    EXPORT,
+	"try":       TRY,
+	"catch":     CATCH,
+	"struct":    STRUCT,
+	"match":     MATCH,
+	"and":       AND_KW,
+	"or":        OR_KW,
+	"not":       NOT_KW,
+	"print":     PRINT,
+	"interface": INTERFACE,
+	"then":      THEN,
+	"yield":     YIELD,
+	"typeof":    TYPEOF,
+	"is":        IS,
+}
+
+// scopeModifiers are only keywords in front of an assignment like
+// `outer x = 5`. The lexer reads them as identifiers, so they still work as
+// names and loop labels.
+var scopeModifiers = map[string]TokenType{
+	"outer":  OUTER,
+	"global": GLOBAL,
+}
+
+var names = map[TokenType]string{
+	ILLEGAL: "ILLEGAL",
+	EOF:     "EOF",
+
+	IDENT:  "IDENT",
+	INT:    "INT",
+	FLOAT:  "FLOAT",
+	STRING: "STRING",
+	BYTES:  "BYTES",
+	REGEX:  "REGEX",
+
+	COMMENT: "COMMENT",
+
+	ASSIGN:   "ASSIGN",
+	PLUS:     "PLUS",
+	MINUS:    "MINUS",
+	BANG:     "BANG",
+	ASTERISK: "ASTERISK",
+	SLASH:    "SLASH",
+	LT:       "LT",
+	GT:       "GT",
+
+	EQ:     "EQ",
+	NOT_EQ: "NOT_EQ",
+
+	AND:          "AND",
+	OR:           "OR",
+	NULLCOALESCE: "NULLCOALESCE",
+
+	DOT:         "DOT",
+	ELLIPSIS:    "ELLIPSIS",
+	PIPE:        "PIPE",
+	DOTDOT:      "DOTDOT",
+	DOTDOTLT:    "DOTDOTLT",
+	ARROW:       "ARROW",
+	QUESTIONDOT: "QUESTIONDOT",
+	BAR:         "BAR",
+	CONCAT:      "CONCAT",
+
+	COMMA:     "COMMA",
+	SEMICOLON: "SEMICOLON",
+	NEWLINE:   "NEWLINE",
+	INDENT:    "INDENT",
+	DEDENT:    "DEDENT",
+	COLON:     "COLON",
+	AT:        "AT",
+
+	LPAREN:   "LPAREN",
+	RPAREN:   "RPAREN",
+	LBRACE:   "LBRACE",
+	RBRACE:   "RBRACE",
+	LBRACKET: "LBRACKET",
+	RBRACKET: "RBRACKET",
+
+	FUNCTION:  "FUNCTION",
+	LET:       "LET",
+	CONST:     "CONST",
+	TRUE:      "TRUE",
+	FALSE:     "FALSE",
+	IF:        "IF",
+	UNLESS:    "UNLESS",
+	ELSE:      "ELSE",
+	RETURN:    "RETURN",
+	WHILE:     "WHILE",
+	FOR:       "FOR",
+	IN:        "IN",
+	DO:        "DO",
+	BREAK:     "BREAK",
+	CONTINUE:  "CONTINUE",
+	MACRO:     "MACRO",
+	IMPORT:    "IMPORT",
+	FROM:      "FROM",
+	EXPORT:    "EXPORT",
+	TRY:       "TRY",
+	CATCH:     "CATCH",
+	STRUCT:    "STRUCT",
+	MATCH:     "MATCH",
+	AND_KW:    "AND_KW",
+	OR_KW:     "OR_KW",
+	NOT_KW:    "NOT_KW",
+	PRINT:     "PRINT",
+	INTERFACE: "INTERFACE",
+	THEN:      "THEN",
+	OUTER:     "OUTER",
+	GLOBAL:    "GLOBAL",
+	YIELD:     "YIELD",
+	TYPEOF:    "TYPEOF",
+	IS:        "IS",
+}
+
+const UNKNOWN = "UNKNOWN"
+
+// LookupName returns the name of the constant for t, e.g. "PLUS" for "+",
+// or UNKNOWN if t is not a known token type.
+func LookupName(t TokenType) string {
+	if name, ok := names[t]; ok {
+		return name
+	}
+	return UNKNOWN
+}
+
+func (t TokenType) String() string {
+	return LookupName(t)
 }
 
 func LookupIdent(ident string) TokenType {
@@ -68,3 +258,58 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENT
 }
+
+// LookupScopeModifier returns the token type of a scope modifier like
+// "outer", or false if ident is not one.
+func LookupScopeModifier(ident string) (TokenType, bool) {
+	tok, ok := scopeModifiers[ident]
+	return tok, ok
+}
+
+// SuggestKeyword returns the keyword closest to ident when ident looks like
+// a typo of it, e.g. "return" for "retrun". Short identifiers only match a
+// keyword one edit away so that names like `x` are not mistaken for `fn`, and
+// lengths may differ by one at most.
+func SuggestKeyword(ident string) (string, bool) {
+	best, bestDistance := "", 3
+	for keyword := range keywords {
+		distance := editDistance(ident, keyword)
+		if distance == 0 || distance*3 > len(ident) || len(ident)-len(keyword) > 1 || len(keyword)-len(ident) > 1 {
+			continue
+		}
+		if distance < bestDistance || distance == bestDistance && keyword < best {
+			best, bestDistance = keyword, distance
+		}
+	}
+
+	return best, best != ""
+}
+
+// editDistance returns the number of inserted, deleted, replaced or swapped
+// adjacent bytes needed to turn a into b.
+func editDistance(a, b string) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return rows[len(a)][len(b)]
+}
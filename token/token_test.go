@@ -0,0 +1,167 @@
+package token
+
+import "testing"
+
+func TestLookupName(t *testing.T) {
+	tests := []struct {
+		tokenType TokenType
+		expected  string
+	}{
+		{ILLEGAL, "ILLEGAL"},
+		{EOF, "EOF"},
+		{IDENT, "IDENT"},
+		{INT, "INT"},
+		{FLOAT, "FLOAT"},
+		{STRING, "STRING"},
+		{REGEX, "REGEX"},
+		{COMMENT, "COMMENT"},
+		{ASSIGN, "ASSIGN"},
+		{PLUS, "PLUS"},
+		{MINUS, "MINUS"},
+		{BANG, "BANG"},
+		{ASTERISK, "ASTERISK"},
+		{SLASH, "SLASH"},
+		{LT, "LT"},
+		{GT, "GT"},
+		{EQ, "EQ"},
+		{NOT_EQ, "NOT_EQ"},
+		{AND, "AND"},
+		{OR, "OR"},
+		{NULLCOALESCE, "NULLCOALESCE"},
+		{DOT, "DOT"},
+		{ELLIPSIS, "ELLIPSIS"},
+		{PIPE, "PIPE"},
+		{DOTDOT, "DOTDOT"},
+		{DOTDOTLT, "DOTDOTLT"},
+		{ARROW, "ARROW"},
+		{QUESTIONDOT, "QUESTIONDOT"},
+		{BAR, "BAR"},
+		{COMMA, "COMMA"},
+		{SEMICOLON, "SEMICOLON"},
+		{NEWLINE, "NEWLINE"},
+		{INDENT, "INDENT"},
+		{DEDENT, "DEDENT"},
+		{COLON, "COLON"},
+		{AT, "AT"},
+		{LPAREN, "LPAREN"},
+		{RPAREN, "RPAREN"},
+		{LBRACE, "LBRACE"},
+		{RBRACE, "RBRACE"},
+		{LBRACKET, "LBRACKET"},
+		{RBRACKET, "RBRACKET"},
+		{FUNCTION, "FUNCTION"},
+		{LET, "LET"},
+		{CONST, "CONST"},
+		{TRUE, "TRUE"},
+		{FALSE, "FALSE"},
+		{IF, "IF"},
+		{UNLESS, "UNLESS"},
+		{ELSE, "ELSE"},
+		{RETURN, "RETURN"},
+		{WHILE, "WHILE"},
+		{FOR, "FOR"},
+		{IN, "IN"},
+		{DO, "DO"},
+		{BREAK, "BREAK"},
+		{CONTINUE, "CONTINUE"},
+		{MACRO, "MACRO"},
+		{IMPORT, "IMPORT"},
+		{FROM, "FROM"},
+		{EXPORT, "EXPORT"},
+		{TRY, "TRY"},
+		{CATCH, "CATCH"},
+		{STRUCT, "STRUCT"},
+		{MATCH, "MATCH"},
+		{AND_KW, "AND_KW"},
+		{OR_KW, "OR_KW"},
+		{NOT_KW, "NOT_KW"},
+		{PRINT, "PRINT"},
+		{INTERFACE, "INTERFACE"},
+		{THEN, "THEN"},
+		{OUTER, "OUTER"},
+		{GLOBAL, "GLOBAL"},
+		{YIELD, "YIELD"},
+		{TYPEOF, "TYPEOF"},
+		{IS, "IS"},
+	}
+
+	for _, tt := range tests {
+		if name := LookupName(tt.tokenType); name != tt.expected {
+			t.Errorf("LookupName(%q) wrong. expected=%q, got=%q", string(tt.tokenType), tt.expected, name)
+		}
+
+		if tt.tokenType.String() != tt.expected {
+			t.Errorf("String() of %q wrong. expected=%q, got=%q", string(tt.tokenType), tt.expected, tt.tokenType.String())
+		}
+	}
+}
+
+func TestAllTokenTypesHaveNames(t *testing.T) {
+	for tokenType, name := range names {
+		if name == "" || name == UNKNOWN {
+			t.Errorf("token type %q has no name", string(tokenType))
+		}
+	}
+
+	for _, tokenType := range keywords {
+		if LookupName(tokenType) == UNKNOWN {
+			t.Errorf("keyword token type %q has no name", string(tokenType))
+		}
+	}
+}
+
+func TestLookupNameUnknown(t *testing.T) {
+	if name := LookupName(TokenType("$$")); name != UNKNOWN {
+		t.Errorf("expected %q for unregistered token type, got=%q", UNKNOWN, name)
+	}
+}
+
+func TestLookupScopeModifier(t *testing.T) {
+	tests := []struct {
+		ident    string
+		expected TokenType
+		ok       bool
+	}{
+		{"outer", OUTER, true},
+		{"global", GLOBAL, true},
+		{"let", "", false},
+		{"x", "", false},
+	}
+
+	for _, tt := range tests {
+		tok, ok := LookupScopeModifier(tt.ident)
+		if tok != tt.expected || ok != tt.ok {
+			t.Errorf("LookupScopeModifier(%q) wrong. expected=%q (%t), got=%q (%t)", tt.ident, tt.expected, tt.ok, tok, ok)
+		}
+	}
+
+	if LookupIdent("outer") != IDENT {
+		t.Errorf("outer should be read as an identifier")
+	}
+}
+
+func TestSuggestKeyword(t *testing.T) {
+	tests := []struct {
+		ident    string
+		expected string
+	}{
+		{"retrun", "return"},
+		{"retunr", "return"},
+		{"esle", "else"},
+		{"whiel", "while"},
+		{"contineu", "continue"},
+		{"lett", "let"},
+		{"return", ""},
+		{"x", ""},
+		{"ok", ""},
+		{"counter", ""},
+		{"result", ""},
+	}
+
+	for _, tt := range tests {
+		suggestion, ok := SuggestKeyword(tt.ident)
+		if suggestion != tt.expected || ok != (tt.expected != "") {
+			t.Errorf("SuggestKeyword(%q) wrong. expected=%q, got=%q (%t)", tt.ident, tt.expected, suggestion, ok)
+		}
+	}
+}